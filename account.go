@@ -0,0 +1,186 @@
+package vechain
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/myafeier/log"
+)
+
+// ============子账户============
+
+const (
+	DefaultAccountKey      = "default"       //配置中 UserIdOfYuanZhiLian 对应的账户
+	SubAccountPollInterval = 5 * time.Second //等待子账户创建完成的轮询间隔
+	SubAccountTimeout      = 10 * time.Minute
+)
+
+//子账户状态，与平台 CreateUser.Status 一致
+const (
+	AccountStateProcessing = "PROCESSING"
+	AccountStateSuccess    = "SUCCESS"
+	AccountStateFailure    = "FAILURE"
+)
+
+//本地保存的子账户
+type Account struct {
+	CommonModel `json:",inline" xorm:"extends"`
+	Key         string `json:"key" xorm:"'account_key' varchar(100) default '' unique"` //账户标识
+	Name        string `json:"name" xorm:"varchar(100) default ''"`                     //平台上的账户名
+	RequestNo   string `json:"request_no" xorm:"varchar(100) default ''"`               //创建请求编号，重启后继续查询同一请求
+	Uid         string `json:"uid" xorm:"varchar(100) default ''"`                      //平台分配的用户 Id
+	Status      string `json:"status" xorm:"varchar(20) default ''"`
+}
+
+func (self *Account) TableName() string {
+	return "vechain_account"
+}
+
+// 确保账户 key 已在平台创建，返回其 uid
+//  优先使用 vechain_account 中已保存的 uid；创建中的请求用原请求编号继续查询；否则新建并等待平台处理完成
+func (s *Service) EnsureSubAccount(ctx context.Context, key, name string) (uid string, err error) {
+	session := s.dbEngine.NewSession()
+	defer session.Close()
+
+	account := new(Account)
+	has, err := session.Where("account_key=?", key).Get(account)
+	if err != nil {
+		log.Error("%+v", err.Error())
+		return
+	}
+	if has && account.Status == AccountStateSuccess && account.Uid != "" {
+		uid = account.Uid
+		return
+	}
+
+	if !has || account.Status == AccountStateFailure || account.RequestNo == "" {
+		//先保存请求编号，创建过程中重启不会重复创建
+		account.Key = key
+		account.Name = name
+		account.RequestNo = fmt.Sprintf("A%d", time.Now().UnixNano())
+		account.Status = AccountStateProcessing
+		account.Uid = ""
+		if has {
+			_, err = session.ID(account.Id).Cols("name", "request_no", "status", "uid").Update(account)
+		} else {
+			_, err = session.Insert(account)
+		}
+		if err != nil {
+			log.Error("%+v", err.Error())
+			return
+		}
+		log.Info("create sub account %s(%s), requestNo:%s", key, name, account.RequestNo)
+		_, err = GenerateSubAccount(ctx, s.config, s.Token, account.RequestNo, account.Name, false)
+		if err != nil {
+			return
+		}
+	}
+
+	user, err := s.waitSubAccount(ctx, account.RequestNo, account.Name)
+	if err != nil {
+		return
+	}
+	account.Uid = user.Uid
+	account.Status = user.Status
+	_, err = session.ID(account.Id).Cols("uid", "status").Update(account)
+	if err != nil {
+		log.Error("%+v", err.Error())
+		return
+	}
+	if user.Status != AccountStateSuccess {
+		err = fmt.Errorf("create sub account %s failed, requestNo:%s status:%s", key, account.RequestNo, user.Status)
+		log.Error(err.Error())
+		return
+	}
+	uid = user.Uid
+	return
+}
+
+//轮询直到平台返回 SUCCESS 或 FAILURE
+func (s *Service) waitSubAccount(ctx context.Context, requestNo, name string) (user *CreateUser, err error) {
+	ctx, cancel := context.WithTimeout(ctx, SubAccountTimeout)
+	defer cancel()
+	return WaitCreateUser(ctx, s.config, s.Token, requestNo, name)
+}
+
+//=====================多账户=====================
+// 每个品牌在平台上使用独立的子账户，提交时指定账户标识，命令只包含同一账户的区块
+
+// AccountRegistry 账户标识到平台 uid 的映射
+type AccountRegistry struct {
+	mutex sync.RWMutex
+	uids  map[string]string
+}
+
+func NewAccountRegistry() *AccountRegistry {
+	return &AccountRegistry{uids: make(map[string]string)}
+}
+
+//空标识即默认账户
+func accountKey(key string) string {
+	if key == "" {
+		return DefaultAccountKey
+	}
+	return key
+}
+
+func (self *AccountRegistry) Register(key, uid string) {
+	self.mutex.Lock()
+	defer self.mutex.Unlock()
+	self.uids[accountKey(key)] = uid
+}
+
+func (self *AccountRegistry) Uid(key string) (uid string, err error) {
+	self.mutex.RLock()
+	defer self.mutex.RUnlock()
+	uid, ok := self.uids[accountKey(key)]
+	if !ok || uid == "" {
+		err = fmt.Errorf("unknown sub account: %s", accountKey(key))
+	}
+	return
+}
+
+func (self *AccountRegistry) Keys() (keys []string) {
+	self.mutex.RLock()
+	defer self.mutex.RUnlock()
+	for k := range self.uids {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return
+}
+
+//从配置和 vechain_account 表加载账户
+func (s *Service) loadAccounts() (err error) {
+	if s.config.UserIdOfYuanZhiLian != "" {
+		s.Accounts.Register(DefaultAccountKey, s.config.UserIdOfYuanZhiLian)
+	}
+	for k, v := range s.config.SubAccounts {
+		s.Accounts.Register(k, v)
+	}
+	var accounts []*Account
+	err = s.dbEngine.Where("status=?", AccountStateSuccess).And("uid!=''").Find(&accounts)
+	if err != nil {
+		log.Error("%+v", err.Error())
+		return
+	}
+	for _, v := range accounts {
+		if _, uidErr := s.Accounts.Uid(v.Key); uidErr != nil { //配置优先
+			s.Accounts.Register(v.Key, v.Uid)
+		}
+	}
+	return
+}
+
+// 确保账户在平台上存在并登记，之后可用该标识提交
+func (s *Service) AddSubAccount(ctx context.Context, key, name string) (uid string, err error) {
+	uid, err = s.EnsureSubAccount(ctx, accountKey(key), name)
+	if err != nil {
+		return
+	}
+	s.Accounts.Register(key, uid)
+	return
+}
@@ -0,0 +1,51 @@
+package vechain
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func TestAccountRegistry(t *testing.T) {
+	r := NewAccountRegistry()
+	r.Register("", "U0")
+	r.Register("brand-a", "U1")
+
+	if uid, err := r.Uid(DefaultAccountKey); err != nil || uid != "U0" {
+		t.Errorf("expect default U0, got %s %v", uid, err)
+	}
+	if uid, err := r.Uid(""); err != nil || uid != "U0" {
+		t.Errorf("expect empty key to be default, got %s %v", uid, err)
+	}
+	if uid, err := r.Uid("brand-a"); err != nil || uid != "U1" {
+		t.Errorf("expect U1, got %s %v", uid, err)
+	}
+	if _, err := r.Uid("brand-b"); err == nil {
+		t.Error("expect error for unknown account")
+	}
+	if keys := r.Keys(); len(keys) != 2 || keys[0] != "brand-a" || keys[1] != DefaultAccountKey {
+		t.Errorf("unexpected keys: %v", keys)
+	}
+}
+
+func TestAsyncSubmit_AccountConflict(t *testing.T) {
+	s, cleanup := newTestService(t, &VechainConfig{})
+	defer cleanup()
+	Daemon = s
+	defer func() { Daemon = nil }()
+	s.Accounts.Register("brand-b", "U1")
+	insertTestBlocks(t, s, &Block{Hash: "H1", Vid: "V1", Account: "brand-b", State: BlockStateToOccupy})
+
+	err := AsyncSubmitForAccount(DefaultAccountKey, []string{"H1", "H2"})
+	var conflict *AccountConflictError
+	if !errors.Is(err, ErrHashOwnedByOtherAccount) || !errors.As(err, &conflict) || !reflect.DeepEqual(conflict.Hashes, []string{"H1"}) {
+		t.Fatalf("expect conflict on H1, got %v", err)
+	}
+	//其余 hash 照常提交
+	if has, _ := s.dbEngine.Where("hash=?", "H2").And("account=''").Exist(&Block{}); !has {
+		t.Errorf("H2 should be submitted")
+	}
+	if len(s.CommandChan) != 1 {
+		t.Errorf("expect 1 occupy command, got %d", len(s.CommandChan))
+	}
+}
@@ -0,0 +1,222 @@
+package vechain
+
+import (
+	"bytes"
+	"encoding/base64"
+	"fmt"
+	"html/template"
+	"io"
+	"time"
+
+	"github.com/jung-kurt/gofpdf"
+	"github.com/myafeier/log"
+)
+
+// ============存证证书============
+// 为已上链的区块生成可打印的证书，二维码指向区块链浏览器中的交易
+
+const (
+	CertificateQRSize     = 256 //二维码像素
+	certificateTimeLayout = "2006-01-02 15:04:05 MST"
+)
+
+// CertificateOptions 证书选项
+type CertificateOptions struct {
+	Title string //标题，默认 区块链存证证书 / Blockchain Anchoring Certificate
+	//PDF 使用的 UTF-8 TTF 字体文件，需支持中文；为空时 PDF 使用内置字体，只输出英文标签，标题需为英文
+	FontFile string
+}
+
+// Certificate 存证证书
+type Certificate struct {
+	Title       string
+	Hash        string //数据 hash
+	Vid         string
+	TxId        string
+	ClauseIndex string
+	Submitted   time.Time //提交时间
+	BlockNumber int64
+	BlockTime   time.Time //交易所在区块时间，未确认时为零值
+	ExploreUrl  string
+	QRCode      []byte //ExploreUrl 的二维码 PNG
+	fontFile    string
+	issuedAt    time.Time
+}
+
+//证书字段：中文标签、英文标签、取值
+func (self *Certificate) fields() [][3]string {
+	fields := [][3]string{
+		{"数据哈希", "Data Hash", self.Hash},
+		{"VID", "VID", self.Vid},
+		{"交易 ID", "Transaction ID", self.TxId},
+		{"Clause 索引", "Clause Index", self.ClauseIndex},
+		{"提交时间", "Submitted At", self.Submitted.Format(certificateTimeLayout)},
+	}
+	if !self.BlockTime.IsZero() {
+		fields = append(fields,
+			[3]string{"区块高度", "Block Number", fmt.Sprintf("%d", self.BlockNumber)},
+			[3]string{"上链时间", "Anchored At", self.BlockTime.Format(certificateTimeLayout)},
+		)
+	}
+	fields = append(fields,
+		[3]string{"浏览器地址", "Explorer URL", self.ExploreUrl},
+		[3]string{"证书生成时间", "Issued At", self.issuedAt.Format(certificateTimeLayout)},
+	)
+	return fields
+}
+
+//区块未达到最终状态，不能生成证书
+var ErrBlockNotFinalized = fmt.Errorf("block not finalized")
+
+// 由区块生成证书，区块尚未上链（没有 txid）或未达到最终状态时返回错误（errors.Is 为 ErrBlockNotFinalized）
+//  配置了 ThorNodeUrl 时要求不可逆(Finalized)，未配置时没有链上确认，要求已受理且未回滚
+func NewCertificate(b *Block, config *VechainConfig, options CertificateOptions) (certificate *Certificate, err error) {
+	if b.TxId == "" {
+		err = fmt.Errorf("%s not anchored yet", b.Hash)
+		return
+	}
+	if !b.certifiable(config) {
+		err = fmt.Errorf("%w: %s state %d", ErrBlockNotFinalized, b.Hash, b.State)
+		return
+	}
+	certificate = &Certificate{
+		Title:       options.Title,
+		Hash:        b.Hash,
+		Vid:         b.Vid,
+		TxId:        b.TxId,
+		ClauseIndex: b.ClauseIndex,
+		Submitted:   b.Created,
+		BlockNumber: b.BlockNumber,
+		ExploreUrl:  BlockChainExploreLink(b.TxId, config),
+		fontFile:    options.FontFile,
+		issuedAt:    time.Now(),
+	}
+	if b.BlockTimestamp > 0 {
+		certificate.BlockTime = time.Unix(b.BlockTimestamp, 0)
+	}
+	if certificate.Title == "" {
+		certificate.Title = "区块链存证证书 / Blockchain Anchoring Certificate"
+		if options.FontFile == "" {
+			certificate.Title = "Blockchain Anchoring Certificate"
+		}
+	}
+	certificate.QRCode, err = RenderQR(certificate.ExploreUrl, QROptions{Size: CertificateQRSize})
+	return
+}
+
+//区块是否可以生成证书
+func (b *Block) certifiable(config *VechainConfig) bool {
+	if config.ThorNodeUrl != "" {
+		return b.State == BlockStateFinalized
+	}
+	switch b.State {
+	case BlockStatePosted, BlockStateIncluded, BlockStateFinalized:
+		return true
+	}
+	return false
+}
+
+// 查询 hash 对应的区块并生成证书
+func (s *Service) Certificate(hash string, options CertificateOptions) (certificate *Certificate, err error) {
+	b := new(Block)
+	has, err := s.dbEngine.Where("hash=?", hash).Get(b)
+	if err != nil {
+		log.Error("%+v", err.Error())
+		return
+	}
+	if !has {
+		err = fmt.Errorf("%w: %s", ErrBlockNotFound, hash)
+		return
+	}
+	return NewCertificate(b, s.config, options)
+}
+
+var certificateTemplate = template.Must(template.New("certificate").Parse(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>{{.Title}}</title>
+<style>
+body { font-family: "Helvetica Neue", Arial, "PingFang SC", "Microsoft YaHei", sans-serif; color: #222; }
+.certificate { max-width: 760px; margin: 40px auto; padding: 40px; border: 6px double #2b5797; }
+h1 { text-align: center; color: #2b5797; font-size: 24px; margin: 0 0 32px; }
+table { width: 100%; border-collapse: collapse; }
+th { width: 30%; text-align: left; vertical-align: top; padding: 8px; color: #555; font-weight: normal; }
+td { padding: 8px; word-break: break-all; font-family: Menlo, Consolas, monospace; font-size: 13px; }
+th small { display: block; color: #999; }
+.qr { text-align: center; margin-top: 24px; }
+.qr img { width: 160px; height: 160px; }
+@media print { .certificate { margin: 0; } }
+</style>
+</head>
+<body>
+<div class="certificate">
+<h1>{{.Title}}</h1>
+<table>
+{{- range .Fields}}
+<tr><th>{{index . 0}}<small>{{index . 1}}</small></th><td>{{if eq (index . 1) "Explorer URL"}}<a href="{{index . 2}}">{{index . 2}}</a>{{else}}{{index . 2}}{{end}}</td></tr>
+{{- end}}
+</table>
+<div class="qr"><img src="{{.QRCode}}" alt="{{.ExploreUrl}}"></div>
+</div>
+</body>
+</html>
+`))
+
+// 输出自包含的 HTML，二维码以 data URI 内嵌
+func (self *Certificate) WriteHTML(w io.Writer) error {
+	return certificateTemplate.Execute(w, map[string]interface{}{
+		"Title":      self.Title,
+		"Fields":     self.fields(),
+		"ExploreUrl": self.ExploreUrl,
+		"QRCode":     template.URL("data:image/png;base64," + base64.StdEncoding.EncodeToString(self.QRCode)),
+	})
+}
+
+// 输出 A4 PDF
+func (self *Certificate) WritePDF(w io.Writer) error {
+	pdf := gofpdf.New("P", "mm", "A4", "")
+	font := "Helvetica"
+	if self.fontFile != "" {
+		font = "certificate"
+		pdf.AddUTF8Font(font, "", self.fontFile)
+	}
+	pdf.SetMargins(20, 25, 20)
+	pdf.AddPage()
+	pageWidth, _ := pdf.GetPageSize()
+	width := pageWidth - 40
+
+	pdf.SetDrawColor(43, 87, 151)
+	pdf.SetLineWidth(1)
+	pdf.Rect(12, 12, pageWidth-24, 273, "D")
+
+	pdf.SetFont(font, "", 20)
+	pdf.SetTextColor(43, 87, 151)
+	pdf.MultiCell(width, 10, self.Title, "", "C", false)
+	pdf.Ln(10)
+
+	for _, v := range self.fields() {
+		label := v[1]
+		if self.fontFile != "" {
+			label = v[0] + " / " + v[1]
+		}
+		pdf.SetFont(font, "", 10)
+		pdf.SetTextColor(120, 120, 120)
+		pdf.CellFormat(width, 6, label, "", 1, "L", false, 0, "")
+		pdf.SetFont(font, "", 11)
+		pdf.SetTextColor(34, 34, 34)
+		if v[1] == "Explorer URL" {
+			pdf.SetTextColor(43, 87, 151)
+			pdf.MultiCell(width, 6, v[2], "", "L", false)
+			pdf.LinkString(20, pdf.GetY()-6, width, 6, v[2])
+		} else {
+			pdf.MultiCell(width, 6, v[2], "", "L", false)
+		}
+		pdf.Ln(3)
+	}
+
+	size := 50.0
+	pdf.RegisterImageOptionsReader("qrcode", gofpdf.ImageOptions{ImageType: "PNG"}, bytes.NewReader(self.QRCode))
+	pdf.ImageOptions("qrcode", (pageWidth-size)/2, pdf.GetY()+5, size, size, false, gofpdf.ImageOptions{ImageType: "PNG"}, 0, self.ExploreUrl)
+	return pdf.Output(w)
+}
@@ -0,0 +1,66 @@
+package vechain
+
+import (
+	"bytes"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestCertificate(t *testing.T) {
+	config := &VechainConfig{ExploreLink: "https://explore/%s"}
+	b := &Block{CommonModel: CommonModel{Created: time.Unix(1596240000, 0)}, Hash: "0xH1", Vid: "0XV1", TxId: "0x01", ClauseIndex: "3", State: BlockStatePosted, BlockNumber: 100, BlockTimestamp: 1596240010}
+
+	_, err := NewCertificate(&Block{Hash: "0xH2"}, config, CertificateOptions{})
+	if err == nil {
+		t.Error("expect error for block without txid")
+	}
+
+	certificate, err := NewCertificate(b, config, CertificateOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if certificate.ExploreUrl != "https://explore/0x01" || !bytes.HasPrefix(certificate.QRCode, []byte("\x89PNG")) {
+		t.Errorf("unexpected certificate: %s", certificate.ExploreUrl)
+	}
+
+	html := new(bytes.Buffer)
+	if err = certificate.WriteHTML(html); err != nil {
+		t.Fatal(err)
+	}
+	for _, v := range []string{"0xH1", "0XV1", "0x01", `href="https://explore/0x01"`, "data:image/png;base64,", "Block Number"} {
+		if !strings.Contains(html.String(), v) {
+			t.Errorf("html missing %s", v)
+		}
+	}
+
+	pdf := new(bytes.Buffer)
+	if err = certificate.WritePDF(pdf); err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.HasPrefix(pdf.Bytes(), []byte("%PDF-")) {
+		t.Errorf("unexpected pdf header: %q", pdf.Bytes()[:8])
+	}
+}
+
+func TestCertificate_NotFinalized(t *testing.T) {
+	config := &VechainConfig{ExploreLink: "https://explore/%s"}
+	reverted := &Block{Hash: "0xH1", TxId: "0x01", State: BlockStateReverted}
+	if _, err := NewCertificate(reverted, config, CertificateOptions{}); !errors.Is(err, ErrBlockNotFinalized) {
+		t.Errorf("expect ErrBlockNotFinalized for reverted block, got %v", err)
+	}
+
+	//配置了节点时只有不可逆的区块可以生成证书
+	config.ThorNodeUrl = "http://thor"
+	for _, state := range []BlockState{BlockStatePosted, BlockStateIncluded, BlockStateReverted} {
+		b := &Block{Hash: "0xH1", TxId: "0x01", State: state}
+		if _, err := NewCertificate(b, config, CertificateOptions{}); !errors.Is(err, ErrBlockNotFinalized) {
+			t.Errorf("state %d: expect ErrBlockNotFinalized, got %v", state, err)
+		}
+	}
+	b := &Block{Hash: "0xH1", TxId: "0x01", State: BlockStateFinalized}
+	if _, err := NewCertificate(b, config, CertificateOptions{}); err != nil {
+		t.Error(err)
+	}
+}
@@ -1,28 +1,75 @@
 package vechain
 
 import (
-	"crypto/sha256"
 	"fmt"
-	"math/rand"
-	"time"
+	"strconv"
+	"strings"
 )
 
 type BlockState int8
 
 const (
-	BlockStateToOccupy BlockState = 1 //待抢占
-	BlockStateToPost   BlockState = 2 //待上链
-	BlockStatePosted   BlockState = 3 //已上链
+	BlockStateToOccupy  BlockState = 1 //待抢占
+	BlockStateToPost    BlockState = 2 //待上链
+	BlockStatePosted    BlockState = 3 //ToolChain 已受理（接口返回 SUCCESS）
+	BlockStateIncluded  BlockState = 4 //交易已打包进区块
+	BlockStateFinalized BlockState = 5 //交易已达到确认数，不可逆
+	BlockStateReverted  BlockState = 6 //交易被回滚，上链失败
 )
 
+var blockStateNames = map[BlockState]string{
+	BlockStateToOccupy:  "to_occupy",
+	BlockStateToPost:    "to_post",
+	BlockStatePosted:    "posted",
+	BlockStateIncluded:  "included",
+	BlockStateFinalized: "finalized",
+	BlockStateReverted:  "reverted",
+}
+
+//状态名，用于导出和命令行
+func (s BlockState) Name() string {
+	if name, ok := blockStateNames[s]; ok {
+		return name
+	}
+	return strconv.Itoa(int(s))
+}
+
+// 按状态名或数字解析状态
+func ParseBlockState(name string) (state BlockState, err error) {
+	for k, v := range blockStateNames {
+		if strings.EqualFold(v, name) {
+			return k, nil
+		}
+	}
+	i, err := strconv.Atoi(name)
+	if err != nil || blockStateNames[BlockState(i)] == "" {
+		err = fmt.Errorf("invalid block state: %s", name)
+		return
+	}
+	state = BlockState(i)
+	return
+}
+
+//已提交上链、等待链上确认的状态
+func (s BlockState) Confirming() bool {
+	return s == BlockStatePosted || s == BlockStateIncluded
+}
+
 type Block struct {
 	CommonModel      `json:",inline" xorm:"extends"`
 	Hash             string     `json:"hash"  xorm:"varchar(100) default '' index" `  //Hash值
 	Vid              string     `json:"vid" xorm:"varchar(100) default ''"`           //occupy 之后的vid
+	VidAttempt       int        `json:"vid_attempt" xorm:"default 0"`                 //抢占失败重新生成 vid 的次数
+	ScanUrl          string     `json:"scan_url" xorm:"varchar(500) default ''"`      //抢占接口返回的 url，属于整个抢占请求，同一批区块相同
 	TxId             string     `json:"tx_id"  xorm:"varchar(100) default ''"`        //交易ID
 	ClauseIndex      string     `json:"clause_index"  xorm:"varchar(100) default ''"` //上链分批索引
 	State            BlockState `json:"state" xorm:"tinyint(2) default 0 index"`      //区块状态
 	CurrentCommandId int64      `json:"current_command_id" xorm:"default 0 index"`    //当前进行中的命令id，停留在最后一个命令的id
+	Account          string     `json:"account" xorm:"varchar(100) default '' index"` //子账户标识，空为默认账户
+	BlockNumber      int64      `json:"block_number" xorm:"default 0"`                //交易所在区块高度
+	BlockTimestamp   int64      `json:"block_timestamp" xorm:"default 0"`             //交易所在区块时间戳
+	Reverted         bool       `json:"reverted" xorm:"default 0"`                    //交易是否被回滚
+	Confirmations    int64      `json:"confirmations" xorm:"default 0"`               //确认深度
 	ExplorUrl        string     `json:"explor_url" xorm:"-"`
 }
 
@@ -30,9 +77,21 @@ func (self *Block) TableName() string {
 	return "vechain_block"
 }
 
-func (b *Block) GenerateVid() {
-	rand.Seed(time.Now().UnixNano())
-	b.Vid = fmt.Sprintf("0X%X", sha256.Sum256([]byte(fmt.Sprintf("%s%d", b.Hash, rand.Int63()))))
+//按 generator 生成 vid，generator 为空时使用默认生成方式
+func (b *Block) GenerateVid(generator VidGenerator) (err error) {
+	if generator == nil {
+		generator = defaultVidGenerator
+	}
+	vid, err := generator.Generate(b, b.VidAttempt)
+	if err != nil {
+		return
+	}
+	err = ValidateVid(vid)
+	if err != nil {
+		return
+	}
+	b.Vid = vid
+	return
 }
 func (b *Block) GetExplorUrl() {
 	b.ExplorUrl = BlockChainExploreLink(b.TxId, Daemon.config)
@@ -0,0 +1,86 @@
+// vechain-server 以 HTTP 接口提供唯链上链服务
+//
+//  vechain-server [-config vechain.yaml] [-dsn user:pass@tcp(host:3306)/db] [-addr :8080]
+//
+// 接口说明见 Service.HTTPHandler，API Key 在配置 ApiKeys 中设置
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	_ "github.com/go-sql-driver/mysql"
+	"github.com/myafeier/log"
+	"github.com/myafeier/vechain"
+	"xorm.io/xorm"
+)
+
+var (
+	configPath = flag.String("config", os.Getenv("VECHAIN_CONFIG"), "YAML config file, read config from VECHAIN_* env when empty")
+	dsn        = flag.String("dsn", os.Getenv("VECHAIN_DSN"), "mysql dsn, e.g. user:pass@tcp(127.0.0.1:3306)/db?charset=utf8mb4")
+	addr       = flag.String("addr", envOr("VECHAIN_LISTEN", ":8080"), "listen address")
+)
+
+func envOr(name, value string) string {
+	if v := os.Getenv(name); v != "" {
+		return v
+	}
+	return value
+}
+
+func main() {
+	flag.Parse()
+	err := run()
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "vechain-server:", err)
+		os.Exit(1)
+	}
+}
+
+func run() (err error) {
+	var config *vechain.VechainConfig
+	if *configPath != "" {
+		config, err = vechain.LoadConfig(*configPath)
+	} else {
+		config, err = vechain.ConfigFromEnv("")
+	}
+	if err != nil {
+		return
+	}
+	if len(config.ApiKeys) == 0 {
+		return fmt.Errorf("ApiKeys is required")
+	}
+	if *dsn == "" {
+		return fmt.Errorf("-dsn or VECHAIN_DSN is required")
+	}
+	engine, err := xorm.NewEngine("mysql", *dsn)
+	if err != nil {
+		return
+	}
+	err = vechain.InitService(engine, config)
+	if err != nil {
+		return
+	}
+
+	server := &http.Server{Addr: *addr, Handler: vechain.Daemon.HTTPHandler()}
+	go func() {
+		stop := make(chan os.Signal, 1)
+		signal.Notify(stop, syscall.SIGINT, syscall.SIGTERM)
+		<-stop
+		ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+		defer cancel()
+		server.Shutdown(ctx)
+	}()
+	log.Info("listen on %s", *addr)
+	err = server.ListenAndServe()
+	if err == http.ErrServerClosed {
+		err = nil
+	}
+	return
+}
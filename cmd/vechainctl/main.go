@@ -0,0 +1,630 @@
+// vechainctl 唯链客户端运维工具
+//
+//  vechainctl [-config vechain.yaml] [-dsn user:pass@tcp(host:3306)/db] <command> [args]
+//
+//  submit [-account key] [-file path] [-wait] [hash...]   提交hash，未指定参数和文件时从标准输入读取
+//  import [-format csv|jsonl] [-column hash] [-chunk 1000] <file>  批量导入，中断后重新执行从断点继续
+//  export [-format csv|jsonl|json] [-state finalized] [-o file]  导出区块
+//  status <hash>                                          查看区块状态
+//  certificate [-format html|pdf] [-o file] <hash>        生成存证证书
+//  qr [-format png|svg] [-size 256] [-level M] [-target scan] [-file path] -o out <hash...>  生成二维码，多个 hash 时输出 zip
+//  commands list [-state FAIL] [-limit 50]                列出命令
+//  commands retry [-timeout 10m] <id>                     重新执行命令
+//  token [-refresh]                                       打印（或刷新后打印）token，刷新要求 TokenStore 为 db 或 file
+//  account create -key <key> -name <name>                 创建并登记子账户
+//  explore <txid>                                         打印区块链浏览器地址
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	_ "github.com/go-sql-driver/mysql"
+	"github.com/myafeier/log"
+	"github.com/myafeier/vechain"
+	"xorm.io/xorm"
+)
+
+const usage = `usage: vechainctl [flags] <command> [args]
+
+commands:
+  submit [-account key] [-file path] [-wait] [-timeout 10m] [hash...]
+  import [-account key] [-format csv|jsonl] [-column hash] [-chunk 1000] [-checkpoint path] <file>
+  export [-format csv|jsonl|json] [-state finalized,reverted] [-account key]
+         [-created-from 2020-08-01] [-created-to ...] [-updated-from ...] [-updated-to ...] [-o file]
+  status <hash>
+  certificate [-format html|pdf] [-title text] [-font file.ttf] [-o file] <hash>
+  qr [-format png|svg] [-size 256] [-level L|M|Q|H] [-target scan|vid|explore] [-file path] -o out <hash...>
+  commands list [-state FAIL] [-limit 50]
+  commands retry [-timeout 10m] <id>
+  token [-refresh]
+  pool [-fill] [-timeout 10m]
+  account create -key <key> -name <name>
+  explore <txid>
+
+flags:
+`
+
+var (
+	configPath = flag.String("config", os.Getenv("VECHAIN_CONFIG"), "YAML config file, read config from VECHAIN_* env when empty")
+	dsn        = flag.String("dsn", os.Getenv("VECHAIN_DSN"), "mysql dsn, e.g. user:pass@tcp(127.0.0.1:3306)/db?charset=utf8mb4")
+	verbose    = flag.Bool("v", false, "show library logs")
+)
+
+func main() {
+	flag.Usage = func() {
+		fmt.Fprint(os.Stderr, usage)
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+	if flag.NArg() == 0 {
+		flag.Usage()
+		os.Exit(2)
+	}
+	if *verbose {
+		log.SetLogLevel(log.DEBUG)
+	} else {
+		log.SetLogLevel(log.FATAL)
+	}
+
+	var err error
+	args := flag.Args()
+	switch args[0] {
+	case "submit":
+		err = submit(args[1:])
+	case "import":
+		err = importFile(args[1:])
+	case "export":
+		err = export(args[1:])
+	case "status":
+		err = status(args[1:])
+	case "certificate":
+		err = certificate(args[1:])
+	case "qr":
+		err = qr(args[1:])
+	case "commands":
+		err = commands(args[1:])
+	case "token":
+		err = token(args[1:])
+	case "pool":
+		err = pool(args[1:])
+	case "account":
+		err = account(args[1:])
+	case "explore":
+		err = explore(args[1:])
+	default:
+		flag.Usage()
+		os.Exit(2)
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "vechainctl:", err)
+		os.Exit(1)
+	}
+}
+
+func loadConfig() (config *vechain.VechainConfig, err error) {
+	if *configPath != "" {
+		return vechain.LoadConfig(*configPath)
+	}
+	return vechain.ConfigFromEnv("")
+}
+
+//连接数据库并启动服务，用于需要执行命令的 submit、import、commands retry、pool -fill
+func initService() (service *vechain.Service, err error) {
+	config, engine, err := openDB()
+	if err != nil {
+		return
+	}
+	err = vechain.InitService(engine, config)
+	if err != nil {
+		return
+	}
+	service = vechain.Daemon
+	return
+}
+
+//只连接数据库、读取配置，不启动守护进程、不创建子账户，用于查询和管理命令
+func openService() (service *vechain.Service, err error) {
+	config, engine, err := openDB()
+	if err != nil {
+		return
+	}
+	return vechain.NewService(engine, config)
+}
+
+func openDB() (config *vechain.VechainConfig, engine *xorm.Engine, err error) {
+	config, err = loadConfig()
+	if err != nil {
+		return
+	}
+	if *dsn == "" {
+		err = fmt.Errorf("-dsn or VECHAIN_DSN is required")
+		return
+	}
+	engine, err = xorm.NewEngine("mysql", *dsn)
+	return
+}
+
+func submit(args []string) (err error) {
+	fs := flag.NewFlagSet("submit", flag.ExitOnError)
+	accountKey := fs.String("account", vechain.DefaultAccountKey, "sub account key")
+	file := fs.String("file", "", "read hashes from file, one per line")
+	wait := fs.Bool("wait", true, "wait until the submitted commands finish")
+	timeout := fs.Duration("timeout", 10*time.Minute, "max time to wait")
+	fs.Parse(args)
+
+	hashes := fs.Args()
+	if *file != "" {
+		var f *os.File
+		f, err = os.Open(*file)
+		if err != nil {
+			return
+		}
+		defer f.Close()
+		var more []string
+		more, err = readHashes(f)
+		if err != nil {
+			return
+		}
+		hashes = append(hashes, more...)
+	} else if len(hashes) == 0 {
+		hashes, err = readHashes(os.Stdin)
+		if err != nil {
+			return
+		}
+	}
+	if len(hashes) == 0 {
+		err = fmt.Errorf("no hash to submit")
+		return
+	}
+
+	service, err := initService()
+	if err != nil {
+		return
+	}
+	err = vechain.AsyncSubmitForAccount(*accountKey, hashes)
+	submitted := len(hashes)
+	var conflict *vechain.AccountConflictError
+	if errors.As(err, &conflict) {
+		for _, v := range conflict.Hashes {
+			fmt.Fprintf(os.Stderr, "skipped %s: owned by other account\n", v)
+		}
+		submitted -= len(conflict.Hashes)
+		err = nil
+	}
+	if err != nil {
+		return
+	}
+	fmt.Printf("submitted %d hashes\n", submitted)
+	if !*wait {
+		return
+	}
+	deadline := time.Now().Add(*timeout)
+	for service.RunningCommands() > 0 {
+		if time.Now().After(deadline) {
+			err = fmt.Errorf("timeout, %d commands still running", service.RunningCommands())
+			return
+		}
+		time.Sleep(time.Second)
+	}
+	return
+}
+
+//每行一个 hash，忽略空行和 # 开头的注释
+func readHashes(r io.Reader) (hashes []string, err error) {
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		hashes = append(hashes, line)
+	}
+	err = scanner.Err()
+	return
+}
+
+func importFile(args []string) (err error) {
+	fs := flag.NewFlagSet("import", flag.ExitOnError)
+	options := vechain.ImportOptions{}
+	fs.StringVar(&options.Account, "account", vechain.DefaultAccountKey, "sub account key")
+	fs.StringVar(&options.Format, "format", "", "csv or jsonl, detected by file extension when empty")
+	fs.StringVar(&options.Column, "column", "hash", "CSV column or JSONL field holding the hash")
+	fs.IntVar(&options.ChunkSize, "chunk", vechain.ImportChunkSize, "hashes per submission")
+	fs.StringVar(&options.CheckpointFile, "checkpoint", "", "checkpoint file, <file>.checkpoint when empty")
+	fs.Parse(args)
+	if fs.NArg() != 1 {
+		return fmt.Errorf("usage: import [flags] <file>")
+	}
+
+	service, err := initService()
+	if err != nil {
+		return
+	}
+	report, err := service.Import(context.Background(), fs.Arg(0), options)
+	if report != nil {
+		if printErr := printJSON(report); err == nil {
+			err = printErr
+		}
+	}
+	return
+}
+
+func export(args []string) (err error) {
+	fs := flag.NewFlagSet("export", flag.ExitOnError)
+	format := fs.String("format", vechain.ExportFormatCSV, "csv, jsonl or json")
+	states := fs.String("state", "", "comma separated block states, e.g. finalized,reverted")
+	output := fs.String("o", "", "output file, stdout when empty")
+	filter := vechain.ExportFilter{}
+	fs.StringVar(&filter.Account, "account", "", "sub account key, all accounts when empty")
+	timeFlags := map[string]*time.Time{
+		"created-from": &filter.CreatedFrom,
+		"created-to":   &filter.CreatedTo,
+		"updated-from": &filter.UpdatedFrom,
+		"updated-to":   &filter.UpdatedTo,
+	}
+	for name, t := range timeFlags {
+		fs.Var((*timeValue)(t), name, "RFC3339 time or date (2006-01-02), local time zone")
+	}
+	fs.Parse(args)
+	if *states != "" {
+		for _, v := range strings.Split(*states, ",") {
+			var state vechain.BlockState
+			state, err = vechain.ParseBlockState(strings.TrimSpace(v))
+			if err != nil {
+				return
+			}
+			filter.States = append(filter.States, state)
+		}
+	}
+
+	service, err := openService()
+	if err != nil {
+		return
+	}
+	w := os.Stdout
+	if *output != "" {
+		w, err = os.Create(*output)
+		if err != nil {
+			return
+		}
+		defer func() {
+			if closeErr := w.Close(); err == nil {
+				err = closeErr
+			}
+		}()
+	}
+	n, err := service.Export(context.Background(), w, *format, filter)
+	if err != nil {
+		return
+	}
+	fmt.Fprintf(os.Stderr, "exported %d blocks\n", n)
+	return
+}
+
+//时间参数，支持 RFC3339 或日期
+type timeValue time.Time
+
+func (self *timeValue) String() string {
+	if self == nil || time.Time(*self).IsZero() {
+		return ""
+	}
+	return time.Time(*self).Format(time.RFC3339)
+}
+
+func (self *timeValue) Set(s string) (err error) {
+	t, err := time.Parse(time.RFC3339, s)
+	if err != nil {
+		t, err = time.ParseInLocation("2006-01-02", s, time.Local)
+	}
+	if err != nil {
+		return fmt.Errorf("invalid time %s", s)
+	}
+	*self = timeValue(t)
+	return
+}
+
+func status(args []string) (err error) {
+	if len(args) != 1 {
+		return fmt.Errorf("usage: status <hash>")
+	}
+	service, err := openService()
+	if err != nil {
+		return
+	}
+	b, err := service.GetBlockInfoByUuid(args[0])
+	if err != nil {
+		return
+	}
+	return printJSON(b)
+}
+
+func certificate(args []string) (err error) {
+	fs := flag.NewFlagSet("certificate", flag.ExitOnError)
+	format := fs.String("format", "html", "html or pdf")
+	output := fs.String("o", "", "output file, stdout when empty")
+	options := vechain.CertificateOptions{}
+	fs.StringVar(&options.Title, "title", "", "certificate title")
+	fs.StringVar(&options.FontFile, "font", "", "UTF-8 TTF font for Chinese labels in PDF")
+	fs.Parse(args)
+	if fs.NArg() != 1 {
+		return fmt.Errorf("usage: certificate [flags] <hash>")
+	}
+	if *format != "html" && *format != "pdf" {
+		return fmt.Errorf("unsupported format: %s", *format)
+	}
+
+	service, err := openService()
+	if err != nil {
+		return
+	}
+	c, err := service.Certificate(fs.Arg(0), options)
+	if err != nil {
+		return
+	}
+	w := os.Stdout
+	if *output != "" {
+		w, err = os.Create(*output)
+		if err != nil {
+			return
+		}
+		defer func() {
+			if closeErr := w.Close(); err == nil {
+				err = closeErr
+			}
+		}()
+	}
+	if *format == "pdf" {
+		return c.WritePDF(w)
+	}
+	return c.WriteHTML(w)
+}
+
+func qr(args []string) (err error) {
+	fs := flag.NewFlagSet("qr", flag.ExitOnError)
+	options := vechain.QROptions{}
+	fs.StringVar(&options.Format, "format", vechain.QRFormatPNG, "png or svg")
+	fs.IntVar(&options.Size, "size", vechain.QRDefaultSize, "image size")
+	fs.StringVar(&options.Level, "level", "M", "error correction level: L, M, Q or H")
+	fs.StringVar(&options.Target, "target", vechain.QRTargetScan, "scan, vid or explore")
+	file := fs.String("file", "", "read hashes from file, one per line; output is a zip archive")
+	output := fs.String("o", "", "output file")
+	fs.Parse(args)
+
+	hashes := fs.Args()
+	if *file != "" {
+		var f *os.File
+		f, err = os.Open(*file)
+		if err != nil {
+			return
+		}
+		defer f.Close()
+		var more []string
+		more, err = readHashes(f)
+		if err != nil {
+			return
+		}
+		hashes = append(hashes, more...)
+	}
+	if len(hashes) == 0 || *output == "" {
+		return fmt.Errorf("usage: qr [flags] -o out <hash...>")
+	}
+
+	service, err := openService()
+	if err != nil {
+		return
+	}
+	if len(hashes) == 1 && *file == "" {
+		var data []byte
+		data, err = service.BlockQR(hashes[0], options)
+		if err != nil {
+			return
+		}
+		return ioutil.WriteFile(*output, data, 0644)
+	}
+	w, err := os.Create(*output)
+	if err != nil {
+		return
+	}
+	defer func() {
+		if closeErr := w.Close(); err == nil {
+			err = closeErr
+		}
+	}()
+	skipped, err := service.BatchQR(context.Background(), w, hashes, options)
+	if err != nil {
+		return
+	}
+	unique := make(map[string]bool, len(hashes))
+	for _, v := range hashes {
+		unique[v] = true
+	}
+	fmt.Fprintf(os.Stderr, "generated %d qr codes\n", len(unique)-len(skipped))
+	for _, v := range skipped {
+		fmt.Fprintf(os.Stderr, "skipped %s\n", v)
+	}
+	return
+}
+
+func commands(args []string) (err error) {
+	if len(args) == 0 {
+		return fmt.Errorf("usage: commands list|retry")
+	}
+	switch args[0] {
+	case "list":
+		fs := flag.NewFlagSet("commands list", flag.ExitOnError)
+		state := fs.String("state", "", "GENERATING, SUCCESS or FAIL, empty for all")
+		limit := fs.Int("limit", 50, "max rows, 0 for no limit")
+		fs.Parse(args[1:])
+
+		var service *vechain.Service
+		service, err = openService()
+		if err != nil {
+			return
+		}
+		var list []*vechain.CommandModel
+		list, err = service.ListCommands(vechain.CommandState(strings.ToUpper(*state)), *limit)
+		if err != nil {
+			return
+		}
+		w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+		fmt.Fprintln(w, "ID\tCMD\tSTATE\tACCOUNT\tCREATED\tERROR")
+		for _, v := range list {
+			fmt.Fprintf(w, "%d\t%s\t%s\t%s\t%s\t%s\n", v.Id, v.Cmd, v.State, v.Account, v.Created.Format("2006-01-02 15:04:05"), v.Error)
+		}
+		return w.Flush()
+	case "retry":
+		fs := flag.NewFlagSet("commands retry", flag.ExitOnError)
+		timeout := fs.Duration("timeout", 10*time.Minute, "max time to wait")
+		fs.Parse(args[1:])
+		if fs.NArg() != 1 {
+			return fmt.Errorf("usage: commands retry [-timeout 10m] <id>")
+		}
+		var id int64
+		id, err = strconv.ParseInt(fs.Arg(0), 10, 64)
+		if err != nil {
+			return
+		}
+		var service *vechain.Service
+		service, err = initService()
+		if err != nil {
+			return
+		}
+		err = service.RetryCommand(id)
+		if err != nil {
+			return
+		}
+		deadline := time.Now().Add(*timeout)
+		for service.RunningCommands() > 0 {
+			if time.Now().After(deadline) {
+				err = fmt.Errorf("timeout, %d commands still running", service.RunningCommands())
+				return
+			}
+			time.Sleep(time.Second)
+		}
+		fmt.Printf("command %d retried\n", id)
+		return
+	default:
+		return fmt.Errorf("usage: commands list|retry")
+	}
+}
+
+func token(args []string) (err error) {
+	fs := flag.NewFlagSet("token", flag.ExitOnError)
+	refresh := fs.Bool("refresh", false, "request a new token before printing")
+	fs.Parse(args)
+
+	service, err := openService()
+	if err != nil {
+		return
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), vechain.TokenRequestTimeout)
+	defer cancel()
+	if *refresh {
+		//刷新后平台上原有的 token 失效，只有共享存储才能让运行中的服务改用新 token
+		if _, shared := service.Token.(*vechain.SharedToken); !shared {
+			return fmt.Errorf("-refresh requires TokenStore db or file, running services would keep the old token")
+		}
+		err = service.Token.UpdateToken(ctx)
+		if err != nil {
+			return
+		}
+	}
+	t, err := service.Token.GetToken(ctx)
+	if err != nil {
+		return
+	}
+	fmt.Println(t)
+	return
+}
+
+func pool(args []string) (err error) {
+	fs := flag.NewFlagSet("pool", flag.ExitOnError)
+	fill := fs.Bool("fill", false, "occupy vids up to VidPoolHighWatermark when at or below VidPoolLowWatermark, and wait until done")
+	timeout := fs.Duration("timeout", 10*time.Minute, "max time to wait for -fill")
+	fs.Parse(args)
+
+	var service *vechain.Service
+	if *fill {
+		service, err = initService()
+	} else {
+		service, err = openService()
+	}
+	if err != nil {
+		return
+	}
+	if *fill {
+		var n int
+		n, err = service.FillVidPool()
+		if err != nil {
+			return
+		}
+		fmt.Fprintf(os.Stderr, "occupying %d vids\n", n)
+		deadline := time.Now().Add(*timeout)
+		for service.RunningCommands() > 0 {
+			if time.Now().After(deadline) {
+				err = fmt.Errorf("timeout, %d commands still running", service.RunningCommands())
+				return
+			}
+			time.Sleep(time.Second)
+		}
+	}
+	stats, err := service.VidPoolStats()
+	if err != nil {
+		return
+	}
+	return printJSON(stats)
+}
+
+func account(args []string) (err error) {
+	if len(args) == 0 || args[0] != "create" {
+		return fmt.Errorf("usage: account create -key <key> -name <name>")
+	}
+	fs := flag.NewFlagSet("account create", flag.ExitOnError)
+	key := fs.String("key", "", "account key used by submit -account")
+	name := fs.String("name", "", "account name on the platform")
+	fs.Parse(args[1:])
+	if *key == "" || *name == "" {
+		return fmt.Errorf("-key and -name are required")
+	}
+
+	service, err := openService()
+	if err != nil {
+		return
+	}
+	uid, err := service.AddSubAccount(context.Background(), *key, *name)
+	if err != nil {
+		return
+	}
+	fmt.Println(uid)
+	return
+}
+
+func explore(args []string) (err error) {
+	if len(args) != 1 {
+		return fmt.Errorf("usage: explore <txid>")
+	}
+	config, err := loadConfig()
+	if err != nil {
+		return
+	}
+	fmt.Println(vechain.BlockChainExploreLink(args[0], config))
+	return
+}
+
+func printJSON(v interface{}) error {
+	encoder := json.NewEncoder(os.Stdout)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(v)
+}
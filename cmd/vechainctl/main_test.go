@@ -0,0 +1,30 @@
+package main
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestReadHashes(t *testing.T) {
+	hashes, err := readHashes(strings.NewReader("0x01\n\n  0x02  \n# comment\n0x03"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Join(hashes, ",") != "0x01,0x02,0x03" {
+		t.Errorf("unexpected hashes: %v", hashes)
+	}
+}
+
+func TestTimeValue(t *testing.T) {
+	var v timeValue
+	if err := v.Set("2020-08-01"); err != nil || !time.Time(v).Equal(time.Date(2020, 8, 1, 0, 0, 0, 0, time.Local)) {
+		t.Errorf("unexpected date: %v %v", time.Time(v), err)
+	}
+	if err := v.Set("2020-08-01T08:00:00Z"); err != nil || !time.Time(v).Equal(time.Date(2020, 8, 1, 8, 0, 0, 0, time.UTC)) {
+		t.Errorf("unexpected time: %v %v", time.Time(v), err)
+	}
+	if err := v.Set("yesterday"); err == nil {
+		t.Error("expect error")
+	}
+}
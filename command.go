@@ -2,16 +2,19 @@ package vechain
 
 import (
 	"context"
+	"encoding/json"
 	"fmt"
 	"github.com/myafeier/log"
 	"strconv"
 	"sync"
+	"time"
 	"xorm.io/xorm"
 )
 
 const (
 	Command_Post_Artifact = "post_artifact"
 	Command_Occupy_Vid    = "occupy_vid"
+	Command_Fill_Vid_Pool = "fill_vid_pool" //为 vid 池抢占
 )
 
 type CommandState string
@@ -31,6 +34,14 @@ type ICommand interface {
 	GetBlocks() []*Block
 }
 
+//命令执行的上下文，超过 expire 后取消
+//  到期时 context 自身的定时器会取消并释放资源；命令可能被重新排队，没有统一的结束点调用 cancel
+func newCommandContext(expire time.Duration) context.Context {
+	ctx, cancel := context.WithDeadline(context.Background(), time.Now().Add(expire))
+	_ = cancel
+	return ctx
+}
+
 func GetCommandById(session *xorm.Session, ctx context.Context, id int64) (cmd ICommand, err error) {
 	cm := &CommandModel{}
 	has, err := session.ID(id).Get(cm)
@@ -54,6 +65,8 @@ func GetCommandById(session *xorm.Session, ctx context.Context, id int64) (cmd I
 		cmdT.state = cm.State
 		cmdT.ctx = ctx
 		cmdT.blocks = blocks
+		cmdT.payload = cm.Payload
+		cmdT.account = cm.Account
 		cmd = cmdT
 
 	case Command_Post_Artifact:
@@ -62,15 +75,21 @@ func GetCommandById(session *xorm.Session, ctx context.Context, id int64) (cmd I
 		cmdT.state = cm.State
 		cmdT.ctx = ctx
 		cmdT.blocks = blocks
+		cmdT.payload = cm.Payload
+		cmdT.account = cm.Account
 		cmd = cmdT
+
+	case Command_Fill_Vid_Pool:
+		cmd, err = newFillVidPoolCommandFromModel(session, ctx, cm)
 	}
 	return
 }
 
-func NewOccupyVidCommand(session *xorm.Session, ctx context.Context, blocks []*Block) (cmd *OccupyVidCommand, err error) {
+func NewOccupyVidCommand(session *xorm.Session, ctx context.Context, generator VidGenerator, account string, blocks []*Block) (cmd *OccupyVidCommand, err error) {
 	cmdM := &CommandModel{}
 	cmdM.State = CommandStateOfGenerating
 	cmdM.Cmd = Command_Occupy_Vid
+	cmdM.Account = account
 	_, err = session.Insert(cmdM)
 	if err != nil {
 		log.Error("%+v", err.Error())
@@ -79,9 +98,18 @@ func NewOccupyVidCommand(session *xorm.Session, ctx context.Context, blocks []*B
 	for k, v := range blocks {
 		v.CurrentCommandId = cmdM.Id
 		v.State = BlockStateToOccupy
-		v.GenerateVid()
+		v.Account = account
+		err = v.GenerateVid(generator)
+		if err != nil {
+			log.Error("%+v", err.Error())
+			return
+		}
 		log.Debug("%d \n", k)
-		_, err = session.Insert(v)
+		if v.Id > 0 { //抢占失败后重新抢占的区块
+			_, err = session.ID(v.Id).Cols("vid", "vid_attempt", "state", "current_command_id").Update(v)
+		} else {
+			_, err = session.Insert(v)
+		}
 		if err != nil {
 			log.Error("%+v", err.Error())
 			return
@@ -92,13 +120,16 @@ func NewOccupyVidCommand(session *xorm.Session, ctx context.Context, blocks []*B
 	cmd.state = cmdM.State
 	cmd.blocks = blocks
 	cmd.ctx = ctx
+	cmd.account = account
+	cmd.payload, err = savePayload(session, cmd.id, cmd.buildRequest())
 	return
 }
 
-func NewPostArtifactCommand(session *xorm.Session, ctx context.Context, blocks []*Block) (cmd *PostArtifactCommand, err error) {
+func NewPostArtifactCommand(session *xorm.Session, ctx context.Context, account, uid string, blocks []*Block) (cmd *PostArtifactCommand, err error) {
 	cmdM := &CommandModel{}
 	cmdM.State = CommandStateOfGenerating
 	cmdM.Cmd = Command_Post_Artifact
+	cmdM.Account = account
 	_, err = session.Insert(cmdM)
 	if err != nil {
 		log.Error("%+v", err.Error())
@@ -107,7 +138,7 @@ func NewPostArtifactCommand(session *xorm.Session, ctx context.Context, blocks [
 	for _, v := range blocks {
 		v.CurrentCommandId = cmdM.Id
 		v.State = BlockStateToPost
-		_, err = session.ID(v.Id).Cols("state", "current_command_id", "").Update(v)
+		_, err = session.ID(v.Id).Cols("state", "current_command_id", "scan_url").Update(v)
 		if err != nil {
 			log.Error("%+v", err.Error())
 			return
@@ -118,6 +149,23 @@ func NewPostArtifactCommand(session *xorm.Session, ctx context.Context, blocks [
 	cmd.state = cmdM.State
 	cmd.blocks = blocks
 	cmd.ctx = ctx
+	cmd.account = account
+	cmd.payload, err = savePayload(session, cmd.id, cmd.buildRequest(uid))
+	return
+}
+
+//保存命令的请求报文，重试时原样重发，保证同一 requestNo 的报文不变
+func savePayload(session xorm.Interface, id int64, request interface{}) (payload string, err error) {
+	data, err := json.Marshal(request)
+	if err != nil {
+		log.Error("%+v", err.Error())
+		return
+	}
+	payload = string(data)
+	_, err = session.ID(id).Cols("payload").Update(&CommandModel{Payload: payload})
+	if err != nil {
+		log.Error("%+v", err.Error())
+	}
 	return
 }
 
@@ -125,6 +173,8 @@ type OccupyVidCommand struct {
 	id          int64
 	state       CommandState
 	blocks      []*Block
+	payload     string //持久化的请求报文
+	account     string //子账户标识
 	successChan chan *Block
 	ctx         context.Context
 }
@@ -132,17 +182,16 @@ type OccupyVidCommand struct {
 func (self *OccupyVidCommand) Execute(service *Service) (err error) {
 	defer func() {
 		if err != nil {
-			_, err = service.dbEngine.NewSession().ID(self.id).Update(&CommandModel{Error: err.Error(), State: CommandStateOfFail})
+			service.publishCommandFailed(self.id, self.account, self.blocks, err.Error())
+			_, err = service.dbEngine.ID(self.id).Update(&CommandModel{Error: err.Error(), State: CommandStateOfFail})
 		}
 		service.RunningCommandIds.Delete(self.id)
 		log.Debug("complete OccupyVidCommand!")
 	}()
 
-	request := &OccupyVidRequest{}
-	request.RequestNo = strconv.FormatInt(self.id, 10)
-	for _, v := range self.blocks {
-		request.VidList = append(request.VidList, v.Vid)
-		//log.Debug("vid:%s \n",v.Vid)
+	request, err := self.loadRequest(service)
+	if err != nil {
+		return
 	}
 
 	ctx := context.WithValue(self.ctx, "request", request)
@@ -155,16 +204,22 @@ func (self *OccupyVidCommand) Execute(service *Service) (err error) {
 	if response.Status == string(CommandStateOfSuccess) {
 		//保存当前command的状态
 		self.state = CommandStateOfSuccess
-		var newCommandIds []int64
-		newCommandIds, err = self.next(service.dbEngine.NewSession(), service.CommandChan, response)
+		var newCommands []ICommand
+		var uid string
+		uid, err = service.Accounts.Uid(self.account)
 		if err != nil {
 			log.Error("%+v", err.Error())
 			return
 		}
-		if newCommandIds != nil {
-			for _, v := range newCommandIds {
-				service.RunningCommandIds.Store(v, true)
-			}
+		newCommands, err = self.next(service.dbEngine.NewSession(), service.vidGenerator(), uid, response)
+		if err != nil {
+			log.Error("%+v", err.Error())
+			return
+		}
+		service.publishBlocks(EventOccupied, self.id, self.occupied())
+		for _, v := range newCommands {
+			service.RunningCommandIds.Store(v.GetId(), true)
+			service.CommandChan <- v
 		}
 	} else {
 		err = fmt.Errorf(response.Status)
@@ -174,9 +229,35 @@ func (self *OccupyVidCommand) Execute(service *Service) (err error) {
 
 	return
 }
-func (self *OccupyVidCommand) next(session *xorm.Session, commandChan chan ICommand, response *OccupyVidResponse) (newCommandId []int64, err error) {
+//持久化的请求报文，升级前创建的命令没有报文时按区块生成并补存
+func (self *OccupyVidCommand) loadRequest(service *Service) (request *OccupyVidRequest, err error) {
+	request = &OccupyVidRequest{}
+	if self.payload != "" {
+		err = json.Unmarshal([]byte(self.payload), request)
+		if err != nil {
+			log.Error("%+v", err.Error())
+		}
+		return
+	}
+	request = self.buildRequest()
+	self.payload, err = savePayload(service.dbEngine, self.id, request)
+	return
+}
+
+func (self *OccupyVidCommand) buildRequest() (request *OccupyVidRequest) {
+	request = &OccupyVidRequest{}
+	request.RequestNo = strconv.FormatInt(self.id, 10)
+	for _, v := range self.blocks {
+		request.VidList = append(request.VidList, v.Vid)
+	}
+	return
+}
+
+//返回后续的上链和重新抢占命令，事务提交后由调用方发送到执行通道
+func (self *OccupyVidCommand) next(session *xorm.Session, generator VidGenerator, uid string, response *OccupyVidResponse) (newCommands []ICommand, err error) {
 	persistMutex.Lock()
 	defer persistMutex.Unlock()
+	defer session.Close()
 	err = session.Begin()
 	if err != nil {
 		log.Error("%+v", err.Error())
@@ -185,9 +266,16 @@ func (self *OccupyVidCommand) next(session *xorm.Session, commandChan chan IComm
 
 	defer func() {
 		if err != nil {
-			err = session.Rollback()
-		} else {
-			err = session.Commit()
+			newCommands = nil
+			if rollbackErr := session.Rollback(); rollbackErr != nil {
+				log.Error("%+v", rollbackErr.Error())
+			}
+			return
+		}
+		err = session.Commit()
+		if err != nil {
+			log.Error("%+v", err.Error())
+			newCommands = nil
 		}
 	}()
 
@@ -208,6 +296,7 @@ func (self *OccupyVidCommand) next(session *xorm.Session, commandChan chan IComm
 				log.Debug("self: %+v", vv)
 				if vv.Vid == v {
 					vv.State = BlockStateToPost
+					vv.ScanUrl = response.Url
 					successBlocks = append(successBlocks, vv)
 					break
 				}
@@ -217,13 +306,12 @@ func (self *OccupyVidCommand) next(session *xorm.Session, commandChan chan IComm
 		//生成新的Post
 		if successBlocks != nil && len(successBlocks) > 0 {
 			var cmd ICommand
-			cmd, err = NewPostArtifactCommand(session, self.ctx, successBlocks)
+			cmd, err = NewPostArtifactCommand(session, self.ctx, self.account, uid, successBlocks)
 			if err != nil {
 				log.Error(err.Error())
 				return
 			}
-			commandChan <- cmd
-			newCommandId = append(newCommandId, cmd.GetId())
+			newCommands = append(newCommands, cmd)
 		}
 
 	}
@@ -231,7 +319,7 @@ func (self *OccupyVidCommand) next(session *xorm.Session, commandChan chan IComm
 		for _, v := range response.FailureList {
 			for _, vv := range self.blocks {
 				if vv.Vid == v {
-					vv.GenerateVid()
+					vv.VidAttempt++ //NewOccupyVidCommand 中重新生成 vid
 					failBlocks = append(failBlocks, vv)
 					break
 				}
@@ -240,13 +328,22 @@ func (self *OccupyVidCommand) next(session *xorm.Session, commandChan chan IComm
 		//生成新的Post
 		if failBlocks != nil && len(failBlocks) > 0 {
 			var cmd ICommand
-			cmd, err = NewOccupyVidCommand(session, self.ctx, failBlocks)
+			cmd, err = NewOccupyVidCommand(session, self.ctx, generator, self.account, failBlocks)
 			if err != nil {
 				log.Error(err.Error())
 				return
 			}
-			commandChan <- cmd
-			newCommandId = append(newCommandId, cmd.GetId())
+			newCommands = append(newCommands, cmd)
+		}
+	}
+	return
+}
+
+//抢占成功的区块，next 之后有效
+func (self *OccupyVidCommand) occupied() (blocks []*Block) {
+	for _, v := range self.blocks {
+		if v.State == BlockStateToPost {
+			blocks = append(blocks, v)
 		}
 	}
 	return
@@ -260,6 +357,8 @@ type PostArtifactCommand struct {
 	id          int64
 	state       CommandState
 	blocks      []*Block
+	payload     string        //持久化的请求报文
+	account     string        //子账户标识
 	successChan chan ICommand `xorm:"-"`
 	ctx         context.Context
 }
@@ -267,19 +366,14 @@ type PostArtifactCommand struct {
 func (self *PostArtifactCommand) Execute(service *Service) (err error) {
 	defer func() {
 		if err != nil {
-			_, err = service.dbEngine.NewSession().ID(self.id).Update(&CommandModel{Error: err.Error(), State: CommandStateOfFail})
+			service.publishCommandFailed(self.id, self.account, self.blocks, err.Error())
+			_, err = service.dbEngine.ID(self.id).Update(&CommandModel{Error: err.Error(), State: CommandStateOfFail})
 		}
 		service.RunningCommandIds.Delete(self.id)
 	}()
-	request := &PostArtifactRequest{}
-	request.RequestNo = strconv.FormatInt(self.id, 10)
-	request.Uid = service.config.UserIdOfYuanZhiLian
-
-	for _, v := range self.blocks {
-		requestData := new(PostArtifactRequestData)
-		requestData.Vid = v.Vid
-		requestData.DataHash = v.Hash
-		request.Data = append(request.Data, requestData)
+	request, err := self.loadRequest(service)
+	if err != nil {
+		return
 	}
 
 	ctx := context.WithValue(self.ctx, "request", request)
@@ -291,11 +385,13 @@ func (self *PostArtifactCommand) Execute(service *Service) (err error) {
 	}
 	if response.Status == string(CommandStateOfSuccess) {
 		self.state = CommandStateOfSuccess
-		err = self.next(service.dbEngine.NewSession(), service.SuccessChan, response)
+		var posted []*Block
+		posted, err = self.next(service.dbEngine.NewSession(), service.postSuccessChan(), response)
 		if err != nil {
 			log.Error("%+v", err.Error())
 			return
 		}
+		service.publishBlocks(EventPosted, self.id, posted)
 	} else if response.Status == "INSUFFICIENT" {
 		err = fmt.Errorf("链上账户余额不足")
 		log.Error("%+v", err.Error())
@@ -308,7 +404,41 @@ func (self *PostArtifactCommand) Execute(service *Service) (err error) {
 	return
 }
 
-func (self *PostArtifactCommand) next(session *xorm.Session, successChan chan *Block, response *PostArtifactResponse) (err error) {
+//持久化的请求报文，升级前创建的命令没有报文时按区块生成并补存
+func (self *PostArtifactCommand) loadRequest(service *Service) (request *PostArtifactRequest, err error) {
+	request = &PostArtifactRequest{}
+	if self.payload != "" {
+		err = json.Unmarshal([]byte(self.payload), request)
+		if err != nil {
+			log.Error("%+v", err.Error())
+		}
+		return
+	}
+	uid, err := service.Accounts.Uid(self.account)
+	if err != nil {
+		log.Error("%+v", err.Error())
+		return
+	}
+	request = self.buildRequest(uid)
+	self.payload, err = savePayload(service.dbEngine, self.id, request)
+	return
+}
+
+func (self *PostArtifactCommand) buildRequest(uid string) (request *PostArtifactRequest) {
+	request = &PostArtifactRequest{}
+	request.RequestNo = strconv.FormatInt(self.id, 10)
+	request.Uid = uid
+	for _, v := range self.blocks {
+		requestData := new(PostArtifactRequestData)
+		requestData.Vid = v.Vid
+		requestData.DataHash = v.Hash
+		request.Data = append(request.Data, requestData)
+	}
+	return
+}
+
+//返回平台已上链、状态改为已受理的区块
+func (self *PostArtifactCommand) next(session *xorm.Session, successChan chan *Block, response *PostArtifactResponse) (posted []*Block, err error) {
 	persistMutex.Lock()
 	defer persistMutex.Unlock()
 	err = session.Begin()
@@ -317,17 +447,27 @@ func (self *PostArtifactCommand) next(session *xorm.Session, successChan chan *B
 		return
 	}
 
+	defer session.Close()
 	defer func() {
 		if err != nil {
-			err = session.Rollback()
-		} else {
-			err = session.Commit()
-			if err == nil {
-				log.Debug("add block to channel...")
-				for _, v := range self.blocks {
-					log.Debug("add block %+v", *v)
-					successChan <- v
-				}
+			posted = nil
+			if rollbackErr := session.Rollback(); rollbackErr != nil {
+				log.Error("%+v", rollbackErr.Error())
+			}
+			return
+		}
+		err = session.Commit()
+		if err != nil {
+			log.Error("%+v", err.Error())
+			posted = nil
+			return
+		}
+		//只有平台返回了上链结果的区块通知观察者，其余仍为待上链
+		if successChan != nil {
+			log.Debug("add block to channel...")
+			for _, v := range posted {
+				log.Debug("add block %+v", *v)
+				successChan <- v
 			}
 		}
 	}()
@@ -355,6 +495,7 @@ func (self *PostArtifactCommand) next(session *xorm.Session, successChan chan *B
 					log.Error(err.Error())
 					return
 				}
+				posted = append(posted, vv)
 				break
 			}
 		}
@@ -372,6 +513,8 @@ type CommandModel struct {
 	Cmd         string       `json:"cmd" xorm:"varchar(30) default '' index"`
 	State       CommandState `json:"state" xorm:"varchar(20) default '' index"`
 	Error       string       `json:"error" xorm:"varchar(1000)"`
+	Payload     string       `json:"payload" xorm:"text"`                          //请求报文，重试时原样重发
+	Account     string       `json:"account" xorm:"varchar(100) default '' index"` //子账户标识，空为默认账户
 }
 
 func (self *CommandModel) GetBlock(session *xorm.Session) (blocks []*Block, err error) {
@@ -0,0 +1,137 @@
+package vechain
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+	"time"
+)
+
+func TestPostArtifactCommand_PayloadStable(t *testing.T) {
+	cmd := &PostArtifactCommand{id: 12, blocks: []*Block{{Hash: "H1", Vid: "V1"}, {Hash: "H\"2", Vid: "V2"}}}
+	data, err := json.Marshal(cmd.buildRequest("uid"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	request := &PostArtifactRequest{}
+	err = json.Unmarshal(data, request)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resend, err := json.Marshal(request)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(resend) != string(data) {
+		t.Errorf("payload changed on resend:\n%s\n%s", data, resend)
+	}
+	if request.RequestNo != "12" || request.Uid != "uid" || len(request.Data) != 2 {
+		t.Errorf("unexpected request: %s", resend)
+	}
+}
+
+func TestRetryCommand_Claim(t *testing.T) {
+	s, cleanup := newTestService(t, &VechainConfig{CommandExpireDuration: time.Hour})
+	defer cleanup()
+	failed := insertTestCommand(t, s, Command_Occupy_Vid, CommandStateOfFail, `{"requestNo":"1"}`)
+	generating := insertTestCommand(t, s, Command_Occupy_Vid, CommandStateOfGenerating, `{"requestNo":"2"}`)
+
+	//另一个进程（RunningCommandIds 独立）
+	other := &Service{dbEngine: s.dbEngine, config: s.config, CommandChan: make(chan ICommand, 10)}
+
+	if err := s.RetryCommand(failed); err != nil {
+		t.Fatal(err)
+	}
+	if err := other.RetryCommand(failed); err != ErrCommandRunning {
+		t.Errorf("claimed command should not be retried by other process, got %v", err)
+	}
+	if err := s.RetryCommand(generating); err != ErrCommandRunning {
+		t.Errorf("recently updated command should not be retried, got %v", err)
+	}
+	if len(s.CommandChan) != 1 || len(other.CommandChan) != 0 {
+		t.Errorf("expect exactly one queued command, got %d %d", len(s.CommandChan), len(other.CommandChan))
+	}
+	if _, running := s.RunningCommandIds.Load(generating); running {
+		t.Errorf("running flag should be cleared when claim fails")
+	}
+
+	//执行中但超时未更新，原进程已退出
+	expired := &CommandModel{CommonModel: CommonModel{Updated: time.Now().Add(-2 * time.Hour)}}
+	if _, err := s.dbEngine.ID(generating).NoAutoTime().Cols("updated").Update(expired); err != nil {
+		t.Fatal(err)
+	}
+	if err := other.RetryCommand(generating); err != nil {
+		t.Errorf("expired command should be claimed, got %v", err)
+	}
+}
+
+func TestPostArtifactCommand_nextPartial(t *testing.T) {
+	s, cleanup := newTestService(t, &VechainConfig{})
+	defer cleanup()
+	blocks := insertTestBlocks(t, s,
+		&Block{Hash: "H1", Vid: "V1", State: BlockStateToPost},
+		&Block{Hash: "H2", Vid: "V2", State: BlockStateToPost},
+	)
+	session := s.dbEngine.NewSession()
+	cmd, err := NewPostArtifactCommand(session, context.Background(), DefaultAccountKey, "U0", blocks)
+	session.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	//平台只返回了 V1
+	response := &PostArtifactResponse{Status: "SUCCESS", TxList: []*PostArtifactResponseData{{TxId: "0x01", Vid: "V1", DataHash: "H1"}}}
+	posted, err := cmd.next(s.dbEngine.NewSession(), s.SuccessChan, response)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(posted) != 1 || posted[0].Hash != "H1" {
+		t.Errorf("expect only H1 posted, got %d", len(posted))
+	}
+	if len(s.SuccessChan) != 1 {
+		t.Fatalf("expect 1 block in success channel, got %d", len(s.SuccessChan))
+	}
+	if b := <-s.SuccessChan; b.Hash != "H1" {
+		t.Errorf("expect H1 in success channel, got %s", b.Hash)
+	}
+	if b := getTestBlock(t, s, blocks[1].Id); b.State != BlockStateToPost || b.TxId != "" {
+		t.Errorf("H2 should stay to post, got %+v", *b)
+	}
+}
+
+func TestOccupyVidCommand_next(t *testing.T) {
+	s, cleanup := newTestService(t, &VechainConfig{})
+	defer cleanup()
+	session := s.dbEngine.NewSession()
+	cmd, err := NewOccupyVidCommand(session, context.Background(), nil, DefaultAccountKey, []*Block{{Hash: "H1"}, {Hash: "H2"}})
+	session.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	response := &OccupyVidResponse{Status: "SUCCESS", SuccessList: []string{cmd.blocks[0].Vid}, FailureList: []string{cmd.blocks[1].Vid}}
+	newCommands, err := cmd.next(s.dbEngine.NewSession(), nil, "U0", response)
+	if err != nil {
+		t.Fatal(err)
+	}
+	//后续命令由调用方在提交后发送
+	if len(newCommands) != 2 || len(s.CommandChan) != 0 {
+		t.Fatalf("expect 2 commands returned and none sent, got %d %d", len(newCommands), len(s.CommandChan))
+	}
+	if _, ok := newCommands[0].(*PostArtifactCommand); !ok {
+		t.Errorf("expect post command first, got %T", newCommands[0])
+	}
+	if _, ok := newCommands[1].(*OccupyVidCommand); !ok {
+		t.Errorf("expect occupy command for failed vid, got %T", newCommands[1])
+	}
+
+	//事务失败时返回原错误，不返回命令
+	if err = s.dbEngine.DropTables(&CommandModel{}); err != nil {
+		t.Fatal(err)
+	}
+	newCommands, err = cmd.next(s.dbEngine.NewSession(), nil, "U0", response)
+	if err == nil || newCommands != nil {
+		t.Errorf("expect error and no command, got %d %v", len(newCommands), err)
+	}
+}
@@ -8,29 +8,77 @@ import (
 	"xorm.io/xorm"
 )
 
+//前三项为对应配置字段的默认值
 const (
 	CheckFailDuration     = 10 * time.Minute //检查错误的时间间隔
 	CommandExpireDuration = 24 * time.Hour   //命令超时时间
 	ItemAmountPerRequest  = 100              //一次抢占包含的vid数量
+	ConfirmCheckDuration  = 1 * time.Minute  //链上确认检查的时间间隔
+	ConfirmAmountPerCheck = 500              //每次链上确认检查时分页读取的区块数量
+	ConfirmDropTimeout    = 1 * time.Hour    //已受理的交易超过该时长在节点上仍找不到，视为已丢弃
+	FinalityConfirmations = 12               //默认的不可逆确认数
+	StuckCheckDuration    = 30 * time.Minute //检查滞留区块的时间间隔
+	StuckThreshold        = 30 * time.Minute //区块停留在待抢占/待上链超过该时长视为滞留
 )
 
 type VechainConfig struct {
+	Environment         string `yaml:"Environment"` //testnet、mainnet 时预设 SiteUrl、ExploreLink、ThorNodeUrl，默认 custom
 	SiteUrl             string `yaml:"SiteUrl"`
 	DeveloperId         string `yaml:"DeveloperId"`
 	DeveloperKey        string `yaml:"DeveloperKey"`
 	VeVid               string `yaml:"VeVid"`
 	Address             string `yaml:"Address"`
-	Nonce               string `yaml:"-"`
+	Nonce               string `yaml:"-"` //Deprecated: 每次请求自动生成新的 nonce，该字段不再使用，仅为兼容保留
 	UserIdOfYuanZhiLian string `yaml:"UserIdOfYuanZhiLian"`
 	ExploreLink         string `yaml:"ExploreLink"`
+	ThorNodeUrl         string `yaml:"ThorNodeUrl"` //VeChainThor 节点地址，为空时不做链上确认
+	//每个 vid 的扫码地址，%s 为 vid，如 https://example.com/trace?vid=%s；为空时扫码二维码的内容为 vid
+	//抢占接口返回的 url 属于整个请求，同一批区块相同，不能作为单个 vid 的扫码地址
+	ScanUrlFormat string `yaml:"ScanUrlFormat"`
+	//触发上链成功观察者的状态：BlockStatePosted/BlockStateIncluded/BlockStateFinalized，
+	//配置了节点时默认为 BlockStateFinalized，否则只能为 BlockStatePosted
+	FinalityLevel BlockState `yaml:"FinalityLevel"`
+	Confirmations int64      `yaml:"Confirmations"` //达到不可逆所需的确认数，默认 FinalityConfirmations
+	TokenStore    string     `yaml:"TokenStore"`    //token 存储：memory（默认）、db、file，多副本部署时使用 db 或 file 共享
+	TokenFile     string     `yaml:"TokenFile"`     //TokenStore 为 file 时的文件路径
+	//凭据文件（YAML，含 DeveloperId/DeveloperKey），设置后替代上面的 DeveloperId/DeveloperKey 并在文件修改后自动轮换
+	CredentialsFile string       `yaml:"CredentialsFile"`
+	Credentials     ICredentials `yaml:"-"` //凭据提供者，为空时使用 DeveloperId/DeveloperKey
+	//UserIdOfYuanZhiLian 为空时，启动时自动创建名为 SubAccountName 的子账户，uid 保存在 vechain_account 表
+	AutoCreateSubAccount bool   `yaml:"AutoCreateSubAccount"`
+	SubAccountName       string `yaml:"SubAccountName"`
+	//其他品牌的子账户：账户标识 => uid，提交时用 AsyncSubmitForAccount 指定账户标识
+	SubAccounts map[string]string `yaml:"SubAccounts"`
+	//HTTP 接口（Service.HTTPHandler）的 API Key，请求头 X-Api-Key 或 Authorization: Bearer 携带，为空时拒绝所有请求
+	ApiKeys []string `yaml:"ApiKeys"`
+	//vid 生成方式：random（默认）、deterministic（hash + 抢占次数）、sequential（数据库计数器），vid 以 VeVid 为前缀
+	VidStrategy string `yaml:"VidStrategy"`
+	//预抢占 vid 池：可分配和抢占中的 vid 不超过 VidPoolLowWatermark 时后台抢占补足到 VidPoolHighWatermark，
+	//提交时优先分配池中的 vid 直接上链；VidPoolHighWatermark 为 0 时不启用；池中的 vid 与区块 hash 无关，不能与 deterministic 同时使用
+	VidPoolHighWatermark int `yaml:"VidPoolHighWatermark"`
+	VidPoolLowWatermark  int `yaml:"VidPoolLowWatermark"` //默认为 VidPoolHighWatermark 的一半
+
+	CheckFailDuration     time.Duration `yaml:"CheckFailDuration"`     //检查错误的时间间隔，默认 10m
+	CommandExpireDuration time.Duration `yaml:"CommandExpireDuration"` //命令超时时间，默认 24h
+	ItemAmountPerRequest  int           `yaml:"ItemAmountPerRequest"`  //一次抢占包含的vid数量，默认 100
+}
+
+//当前使用的凭据提供者
+func (self *VechainConfig) credentials() ICredentials {
+	if self.Credentials != nil {
+		return self.Credentials
+	}
+	return &StaticCredentials{DeveloperId: self.DeveloperId, DeveloperKey: self.DeveloperKey}
 }
 
+//每次申请 token 生成新的随机数
 func Nonce() string {
 	return fmt.Sprintf("%016v", rand.New(rand.NewSource(time.Now().UnixNano())).Int63n(9999999999999999))
 }
 
 func initTable(session *xorm.Session) (err error) {
-	var tables = []interface{}{&Block{}, &CommandModel{}}
+	defer session.Close()
+	var tables = []interface{}{&Block{}, &CommandModel{}, &TokenModel{}, &Account{}, &VidSequence{}, &PooledVid{}}
 
 	for _, v := range tables {
 		var isExist bool
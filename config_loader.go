@@ -0,0 +1,148 @@
+package vechain
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+
+	"gopkg.in/yaml.v2"
+)
+
+// ============配置加载============
+// YAML 文件 + 环境变量覆盖，环境变量名为 前缀_字段名大写下划线形式，如 VECHAIN_SITE_URL
+
+const DefaultEnvPrefix = "VECHAIN"
+
+// 读取 YAML 配置文件，再用 VECHAIN_ 开头的环境变量覆盖，未配置的字段取默认值
+func LoadConfig(path string) (config *VechainConfig, err error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return
+	}
+	config = new(VechainConfig)
+	err = yaml.Unmarshal(data, config)
+	if err != nil {
+		err = fmt.Errorf("%s: %s", path, err.Error())
+		return
+	}
+	err = config.applyEnv(DefaultEnvPrefix)
+	if err != nil {
+		return
+	}
+	config.SetDefaults()
+	return
+}
+
+// 只从环境变量读取配置，prefix 为空时使用 VECHAIN
+func ConfigFromEnv(prefix string) (config *VechainConfig, err error) {
+	if prefix == "" {
+		prefix = DefaultEnvPrefix
+	}
+	config = new(VechainConfig)
+	err = config.applyEnv(prefix)
+	if err != nil {
+		return
+	}
+	config.SetDefaults()
+	return
+}
+
+// 为未配置的字段设置默认值
+func (self *VechainConfig) SetDefaults() {
+	self.applyEnvironment()
+	if self.CheckFailDuration <= 0 {
+		self.CheckFailDuration = CheckFailDuration
+	}
+	if self.CommandExpireDuration <= 0 {
+		self.CommandExpireDuration = CommandExpireDuration
+	}
+	if self.ItemAmountPerRequest <= 0 {
+		self.ItemAmountPerRequest = ItemAmountPerRequest
+	}
+	if self.VidPoolHighWatermark > 0 && self.VidPoolLowWatermark == 0 {
+		self.VidPoolLowWatermark = self.VidPoolHighWatermark / 2
+	}
+}
+
+//环境变量名，SiteUrl => PREFIX_SITE_URL
+func envName(prefix, field string) string {
+	var name []rune
+	runes := []rune(field)
+	for i, r := range runes {
+		if i > 0 && unicode.IsUpper(r) && (unicode.IsLower(runes[i-1]) || (i+1 < len(runes) && unicode.IsLower(runes[i+1]))) {
+			name = append(name, '_')
+		}
+		name = append(name, unicode.ToUpper(r))
+	}
+	return prefix + "_" + string(name)
+}
+
+//用环境变量覆盖带 yaml 标签的字段
+func (self *VechainConfig) applyEnv(prefix string) (err error) {
+	value := reflect.ValueOf(self).Elem()
+	for i := 0; i < value.NumField(); i++ {
+		field := value.Type().Field(i)
+		tag := strings.Split(field.Tag.Get("yaml"), ",")[0]
+		if tag == "" || tag == "-" {
+			continue
+		}
+		name := envName(prefix, tag)
+		env, ok := os.LookupEnv(name)
+		if !ok {
+			continue
+		}
+		err = setField(value.Field(i), env)
+		if err != nil {
+			err = fmt.Errorf("%s: %s", name, err.Error())
+			return
+		}
+	}
+	return
+}
+
+func setField(field reflect.Value, env string) (err error) {
+	if field.Type() == reflect.TypeOf(time.Duration(0)) {
+		var d time.Duration
+		d, err = time.ParseDuration(env)
+		if err == nil {
+			field.SetInt(int64(d))
+		}
+		return
+	}
+	switch field.Kind() {
+	case reflect.String:
+		field.SetString(env)
+	case reflect.Bool:
+		var b bool
+		b, err = strconv.ParseBool(env)
+		if err == nil {
+			field.SetBool(b)
+		}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		var i int64
+		i, err = strconv.ParseInt(env, 10, field.Type().Bits())
+		if err == nil {
+			field.SetInt(i)
+		}
+	case reflect.Slice:
+		if field.Type().Elem().Kind() != reflect.String {
+			err = fmt.Errorf("unsupported type %s", field.Type())
+			return
+		}
+		var items []string
+		for _, v := range strings.Split(env, ",") {
+			if v = strings.TrimSpace(v); v != "" {
+				items = append(items, v)
+			}
+		}
+		field.Set(reflect.ValueOf(items))
+	default:
+		err = fmt.Errorf("unsupported type %s", field.Type())
+	}
+	return
+}
@@ -0,0 +1,66 @@
+package vechain
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestLoadConfig(t *testing.T) {
+	dir, err := ioutil.TempDir("", "vechain-config")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "vechain.yaml")
+	err = ioutil.WriteFile(path, []byte(`
+SiteUrl: https://developer.vetoolchain.cn/api/
+DeveloperId: id
+DeveloperKey: key-from-file
+VeVid: 9227.cn.v
+CommandExpireDuration: 1h
+`), 0600)
+	if err != nil {
+		t.Fatal(err)
+	}
+	os.Setenv("VECHAIN_DEVELOPER_KEY", "key-from-env")
+	os.Setenv("VECHAIN_USER_ID_OF_YUAN_ZHI_LIAN", "uid")
+	os.Setenv("VECHAIN_ITEM_AMOUNT_PER_REQUEST", "50")
+	defer os.Unsetenv("VECHAIN_DEVELOPER_KEY")
+	defer os.Unsetenv("VECHAIN_USER_ID_OF_YUAN_ZHI_LIAN")
+	defer os.Unsetenv("VECHAIN_ITEM_AMOUNT_PER_REQUEST")
+
+	cfg, err := LoadConfig(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cfg.DeveloperId != "id" || cfg.DeveloperKey != "key-from-env" || cfg.UserIdOfYuanZhiLian != "uid" {
+		t.Errorf("unexpected config: %+v", *cfg)
+	}
+	if cfg.CommandExpireDuration != time.Hour || cfg.ItemAmountPerRequest != 50 || cfg.CheckFailDuration != CheckFailDuration {
+		t.Errorf("unexpected durations: %+v", *cfg)
+	}
+}
+
+func TestConfigFromEnv(t *testing.T) {
+	os.Setenv("APP_SITE_URL", "https://developer.vetoolchain.cn/api/")
+	os.Setenv("APP_CHECK_FAIL_DURATION", "bad")
+	defer os.Unsetenv("APP_SITE_URL")
+	defer os.Unsetenv("APP_CHECK_FAIL_DURATION")
+
+	_, err := ConfigFromEnv("APP")
+	if err == nil {
+		t.Fatal("expect error for invalid duration")
+	}
+
+	os.Setenv("APP_CHECK_FAIL_DURATION", "5m")
+	cfg, err := ConfigFromEnv("APP")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cfg.SiteUrl != "https://developer.vetoolchain.cn/api/" || cfg.CheckFailDuration != 5*time.Minute {
+		t.Errorf("unexpected config: %+v", *cfg)
+	}
+}
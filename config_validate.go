@@ -0,0 +1,146 @@
+package vechain
+
+import (
+	"fmt"
+	"net/url"
+	"strings"
+)
+
+// ============配置校验============
+
+//配置校验错误，包含所有无效或缺失的字段
+type ConfigErrors []error
+
+func (self ConfigErrors) Error() string {
+	var msgs []string
+	for _, v := range self {
+		msgs = append(msgs, " - "+v.Error())
+	}
+	return fmt.Sprintf("invalid vechain config (%d errors):\n%s", len(self), strings.Join(msgs, "\n"))
+}
+
+// 规范化并校验配置，返回所有问题而不是第一个
+//  SiteUrl 补全结尾的 /，ThorNodeUrl 去掉结尾的 /
+func (self *VechainConfig) Validate() error {
+	var errs ConfigErrors
+	add := func(format string, args ...interface{}) {
+		errs = append(errs, fmt.Errorf(format, args...))
+	}
+
+	self.SiteUrl = strings.TrimSpace(self.SiteUrl)
+	if self.SiteUrl == "" {
+		add("SiteUrl is required, e.g. https://developer.vetoolchain.cn/api/")
+	} else if err := checkHttpUrl(self.SiteUrl); err != nil {
+		add("SiteUrl %q: %s", self.SiteUrl, err.Error())
+	} else if !strings.HasSuffix(self.SiteUrl, "/") {
+		self.SiteUrl += "/"
+	}
+
+	if self.Credentials == nil && self.CredentialsFile == "" {
+		if self.DeveloperId == "" {
+			add("DeveloperId is required (or set CredentialsFile)")
+		}
+		if self.DeveloperKey == "" {
+			add("DeveloperKey is required (or set CredentialsFile)")
+		}
+	}
+
+	if self.AutoCreateSubAccount {
+		if strings.TrimSpace(self.UserIdOfYuanZhiLian) == "" && strings.TrimSpace(self.SubAccountName) == "" {
+			add("SubAccountName is required when AutoCreateSubAccount is enabled")
+		}
+	} else if strings.TrimSpace(self.UserIdOfYuanZhiLian) == "" {
+		add("UserIdOfYuanZhiLian is required: set its uid, or enable AutoCreateSubAccount with SubAccountName")
+	}
+
+	if self.ExploreLink == "" {
+		add("ExploreLink is required, e.g. https://insight.vecha.in/#/test/%%s")
+	} else if strings.Count(self.ExploreLink, "%s") != 1 || strings.Contains(fmt.Sprintf(self.ExploreLink, "txid"), "%!") {
+		add("ExploreLink %q must contain exactly one %%s for the transaction id and no other format verbs", self.ExploreLink)
+	}
+
+	if self.ScanUrlFormat != "" && (strings.Count(self.ScanUrlFormat, "%s") != 1 || strings.Contains(fmt.Sprintf(self.ScanUrlFormat, "vid"), "%!")) {
+		add("ScanUrlFormat %q must contain exactly one %%s for the vid and no other format verbs", self.ScanUrlFormat)
+	}
+
+	self.ThorNodeUrl = strings.TrimRight(strings.TrimSpace(self.ThorNodeUrl), "/")
+	if self.ThorNodeUrl != "" {
+		if err := checkHttpUrl(self.ThorNodeUrl); err != nil {
+			add("ThorNodeUrl %q: %s", self.ThorNodeUrl, err.Error())
+		}
+	}
+
+	switch self.FinalityLevel {
+	case 0, BlockStatePosted:
+	case BlockStateIncluded, BlockStateFinalized:
+		if self.ThorNodeUrl == "" {
+			add("FinalityLevel %d requires ThorNodeUrl", self.FinalityLevel)
+		}
+	default:
+		add("FinalityLevel %d is invalid, use %d (posted), %d (included) or %d (finalized)", self.FinalityLevel, BlockStatePosted, BlockStateIncluded, BlockStateFinalized)
+	}
+	if self.Confirmations < 0 {
+		add("Confirmations must not be negative")
+	}
+
+	switch self.TokenStore {
+	case "", TokenStoreOfMemory, TokenStoreOfDatabase:
+	case TokenStoreOfFile:
+		if self.TokenFile == "" {
+			add("TokenFile is required when TokenStore is %q", TokenStoreOfFile)
+		}
+	default:
+		add("TokenStore %q is invalid, use %q, %q or %q", self.TokenStore, TokenStoreOfMemory, TokenStoreOfDatabase, TokenStoreOfFile)
+	}
+
+	switch self.VidStrategy {
+	case "", VidStrategyRandom, VidStrategyDeterministic, VidStrategySequential:
+	default:
+		add("VidStrategy %q is invalid, use %q, %q or %q", self.VidStrategy, VidStrategyRandom, VidStrategyDeterministic, VidStrategySequential)
+	}
+	if self.VeVid != "" {
+		if err := ValidateVid(vidPrefix(self.VeVid) + "0"); err != nil {
+			add("VeVid is invalid: %s", err.Error())
+		}
+	}
+
+	errs = append(errs, self.checkEnvironment()...)
+
+	if self.CheckFailDuration < 0 {
+		add("CheckFailDuration must not be negative")
+	}
+	if self.CommandExpireDuration < 0 {
+		add("CommandExpireDuration must not be negative")
+	}
+	if self.ItemAmountPerRequest < 0 {
+		add("ItemAmountPerRequest must not be negative")
+	}
+	if self.VidPoolHighWatermark < 0 || self.VidPoolLowWatermark < 0 {
+		add("VidPoolHighWatermark and VidPoolLowWatermark must not be negative")
+	} else if self.VidPoolLowWatermark >= self.VidPoolHighWatermark && self.VidPoolHighWatermark > 0 {
+		add("VidPoolLowWatermark must be less than VidPoolHighWatermark")
+	}
+	//池中的 vid 以 vid-pool- 占位 hash 生成并按顺序分配，与区块 hash 无关，无法按 hash 重放
+	if self.VidStrategy == VidStrategyDeterministic && self.VidPoolHighWatermark > 0 {
+		add("VidStrategy %q cannot be used with VidPoolHighWatermark, pooled vids are not derived from the block hash", VidStrategyDeterministic)
+	}
+
+	if len(errs) > 0 {
+		return errs
+	}
+	return nil
+}
+
+func checkHttpUrl(raw string) error {
+	u, err := url.Parse(raw)
+	if err != nil {
+		return err
+	}
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return fmt.Errorf("scheme must be http or https")
+	}
+	if u.Host == "" {
+		return fmt.Errorf("host is missing")
+	}
+	return nil
+}
@@ -0,0 +1,173 @@
+package vechain
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestVechainConfig_Validate(t *testing.T) {
+	cfg := &VechainConfig{
+		SiteUrl:             " https://developer.vetoolchain.cn/api",
+		DeveloperId:         "id",
+		DeveloperKey:        "key",
+		UserIdOfYuanZhiLian: "uid",
+		ExploreLink:         "https://insight.vecha.in/#/test/%s",
+		ThorNodeUrl:         "https://sync-testnet.vechain.org/",
+	}
+	err := cfg.Validate()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cfg.SiteUrl != "https://developer.vetoolchain.cn/api/" || cfg.ThorNodeUrl != "https://sync-testnet.vechain.org" {
+		t.Errorf("urls not normalized: %q %q", cfg.SiteUrl, cfg.ThorNodeUrl)
+	}
+
+	cfg = &VechainConfig{
+		SiteUrl:       "developer.vetoolchain.cn/api/",
+		ExploreLink:   "https://insight.vecha.in/#/test/",
+		FinalityLevel: BlockStateFinalized,
+	}
+	err = cfg.Validate()
+	errs, ok := err.(ConfigErrors)
+	if !ok {
+		t.Fatalf("expect ConfigErrors, got %v", err)
+	}
+	for _, field := range []string{"SiteUrl", "DeveloperId", "DeveloperKey", "UserIdOfYuanZhiLian", "ExploreLink", "FinalityLevel"} {
+		if !strings.Contains(errs.Error(), field) {
+			t.Errorf("%s not reported in:\n%s", field, errs.Error())
+		}
+	}
+	if len(errs) != 6 {
+		t.Errorf("expect 6 errors, got %d:\n%s", len(errs), errs.Error())
+	}
+}
+
+func TestVechainConfig_Environment(t *testing.T) {
+	cfg := &VechainConfig{
+		Environment:         EnvironmentMainnet,
+		DeveloperId:         "id",
+		DeveloperKey:        "key",
+		UserIdOfYuanZhiLian: "uid",
+	}
+	cfg.SetDefaults()
+	err := cfg.Validate()
+	if err != nil {
+		t.Fatal(err)
+	}
+	profile := Environments[EnvironmentMainnet]
+	if cfg.SiteUrl != profile.SiteUrl || cfg.ExploreLink != profile.ExploreLink || cfg.ThorNodeUrl != profile.ThorNodeUrl {
+		t.Errorf("profile not applied: %+v", *cfg)
+	}
+
+	//节点可以是自建节点，网络在启动时按创世区块确认
+	cfg = &VechainConfig{
+		Environment:         EnvironmentMainnet,
+		DeveloperId:         "id",
+		DeveloperKey:        "key",
+		UserIdOfYuanZhiLian: "uid",
+		ThorNodeUrl:         "https://node.example.com",
+	}
+	cfg.SetDefaults()
+	if err = cfg.Validate(); err != nil {
+		t.Errorf("custom ThorNodeUrl should be accepted: %v", err)
+	}
+
+	//主网配置了测试网浏览器
+	cfg = &VechainConfig{
+		Environment:         EnvironmentMainnet,
+		DeveloperId:         "id",
+		DeveloperKey:        "key",
+		UserIdOfYuanZhiLian: "uid",
+		ExploreLink:         Environments[EnvironmentTestnet].ExploreLink,
+	}
+	cfg.SetDefaults()
+	err = cfg.Validate()
+	if err == nil || !strings.Contains(err.Error(), "ExploreLink") {
+		t.Errorf("expect ExploreLink mismatch, got %v", err)
+	}
+}
+
+func TestVechainConfig_CheckThorNetwork(t *testing.T) {
+	node := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/blocks/0" {
+			t.Errorf("unexpected path %s", r.URL.Path)
+		}
+		fmt.Fprintf(w, `{"number":0,"id":"%s"}`, Environments[EnvironmentTestnet].GenesisId)
+	}))
+	defer node.Close()
+	thor := NewThorClient(node.URL)
+
+	cfg := &VechainConfig{Environment: EnvironmentTestnet, ThorNodeUrl: node.URL}
+	if err := cfg.checkThorNetwork(context.Background(), thor); err != nil {
+		t.Errorf("testnet node should be accepted: %v", err)
+	}
+	//测试网节点配在主网环境
+	cfg = &VechainConfig{Environment: EnvironmentMainnet, ThorNodeUrl: node.URL}
+	if err := cfg.checkThorNetwork(context.Background(), thor); err == nil || !strings.Contains(err.Error(), "not a mainnet node") {
+		t.Errorf("expect network mismatch, got %v", err)
+	}
+	//自定义环境不检查
+	cfg = &VechainConfig{Environment: EnvironmentCustom, ThorNodeUrl: node.URL}
+	if err := cfg.checkThorNetwork(context.Background(), thor); err != nil {
+		t.Errorf("custom environment should not be checked: %v", err)
+	}
+}
+
+func TestInitService_FailedLeavesDaemonUnset(t *testing.T) {
+	node := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintf(w, `{"number":0,"id":"%s"}`, Environments[EnvironmentTestnet].GenesisId)
+	}))
+	defer node.Close()
+	s, cleanup := newTestService(t, &VechainConfig{})
+	defer cleanup()
+
+	//测试网节点配在主网环境，初始化失败
+	err := InitService(s.dbEngine, &VechainConfig{
+		Environment:         EnvironmentMainnet,
+		DeveloperId:         "id",
+		DeveloperKey:        "key",
+		UserIdOfYuanZhiLian: "uid",
+		ThorNodeUrl:         node.URL,
+	})
+	if err == nil {
+		Daemon = nil
+		t.Fatal("expect network mismatch error")
+	}
+	if Daemon != nil {
+		Daemon = nil
+		t.Error("Daemon should not be set when InitService fails")
+	}
+}
+
+func TestNewService(t *testing.T) {
+	base, cleanup := newTestService(t, &VechainConfig{})
+	defer cleanup()
+
+	//不检查节点网络、不设置 Daemon
+	s, err := NewService(base.dbEngine, &VechainConfig{
+		Environment:         EnvironmentTestnet,
+		DeveloperId:         "id",
+		DeveloperKey:        "key",
+		UserIdOfYuanZhiLian: "uid",
+		SubAccounts:         map[string]string{"brand-b": "U1"},
+		ThorNodeUrl:         "http://127.0.0.1:1",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.closeToken()
+	if Daemon != nil {
+		t.Error("NewService should not set Daemon")
+	}
+	if uid, err := s.Accounts.Uid("brand-b"); err != nil || uid != "U1" {
+		t.Errorf("sub account not loaded: %s %v", uid, err)
+	}
+
+	if _, err = NewService(base.dbEngine, &VechainConfig{}); err == nil {
+		t.Error("expect config error")
+	}
+}
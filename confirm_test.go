@@ -0,0 +1,185 @@
+package vechain
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+//0x01 打包在区块 100，0x04 打包在区块 100 但被回滚，最新区块高度由 best 控制
+func newConfirmTestServer(best *int64) *httptest.Server {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/transactions/0x01/receipt", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"reverted":false,"meta":{"blockID":"0xb1","blockNumber":100,"blockTimestamp":1597111238,"txID":"0x01"}}`))
+	})
+	mux.HandleFunc("/transactions/0x04/receipt", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"reverted":true,"meta":{"blockID":"0xb1","blockNumber":100,"blockTimestamp":1597111238,"txID":"0x04"}}`))
+	})
+	mux.HandleFunc("/blocks/0xb1", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"number":100,"id":"0xb1","isTrunk":true}`))
+	})
+	mux.HandleFunc("/blocks/best", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintf(w, `{"number":%d,"id":"0xbb","isTrunk":true}`, atomic.LoadInt64(best))
+	})
+	return httptest.NewServer(mux)
+}
+
+func TestCheckConfirm(t *testing.T) {
+	best := int64(105)
+	server := newConfirmTestServer(&best)
+	defer server.Close()
+	s, cleanup := newTestService(t, &VechainConfig{ThorNodeUrl: server.URL})
+	defer cleanup()
+	s.Thor = NewThorClient(server.URL)
+	events, cancel := s.Subscribe(EventFilter{Types: []EventType{EventReverted}})
+	defer cancel()
+
+	blocks := insertTestBlocks(t, s,
+		&Block{Hash: "H1", TxId: "0x01", State: BlockStatePosted},
+		&Block{Hash: "H4", TxId: "0x04", State: BlockStatePosted},
+	)
+
+	//深度 5 < 12：已打包
+	s.checkConfirm()
+	b := getTestBlock(t, s, blocks[0].Id)
+	if b.State != BlockStateIncluded || b.BlockNumber != 100 || b.Confirmations != 5 {
+		t.Errorf("expect included, got %+v", *b)
+	}
+	if b = getTestBlock(t, s, blocks[1].Id); b.State != BlockStateReverted || !b.Reverted {
+		t.Errorf("expect reverted, got %+v", *b)
+	}
+	if event := <-events; event.Block.Hash != "H4" {
+		t.Errorf("unexpected reverted event %+v", event)
+	}
+	if len(s.SuccessChan) != 0 {
+		t.Errorf("observers should not fire before finalized")
+	}
+
+	//深度 12：不可逆，只通知一次
+	atomic.StoreInt64(&best, 112)
+	s.checkConfirm()
+	s.checkConfirm()
+	if b = getTestBlock(t, s, blocks[0].Id); b.State != BlockStateFinalized || b.Confirmations != 12 {
+		t.Errorf("expect finalized, got %+v", *b)
+	}
+	if len(s.SuccessChan) != 1 || (<-s.SuccessChan).Hash != "H1" {
+		t.Errorf("expect one success notification")
+	}
+	if len(events) != 0 {
+		t.Errorf("reverted block should not be checked again")
+	}
+}
+
+func TestNotifyState(t *testing.T) {
+	thor := NewThorClient("http://127.0.0.1")
+	cases := []struct {
+		thor  *ThorClient
+		level BlockState
+		state BlockState
+	}{
+		{nil, BlockStateFinalized, BlockStatePosted}, //未配置节点时只能在受理后通知
+		{thor, 0, BlockStateFinalized},
+		{thor, BlockStatePosted, BlockStatePosted},
+		{thor, BlockStateIncluded, BlockStateIncluded},
+		{thor, BlockStateReverted, BlockStateFinalized},
+	}
+	for _, c := range cases {
+		s := &Service{Thor: c.thor, config: &VechainConfig{FinalityLevel: c.level}}
+		if state := s.notifyState(); state != c.state {
+			t.Errorf("thor:%v level:%d expect %d, got %d", c.thor != nil, c.level, c.state, state)
+		}
+	}
+
+	s := &Service{Thor: thor, config: &VechainConfig{FinalityLevel: BlockStateIncluded}}
+	if s.postSuccessChan() != nil || !s.isFinal(BlockStateFinalized) || s.isFinal(BlockStatePosted) || s.isFinal(BlockStateReverted) {
+		t.Errorf("unexpected isFinal/postSuccessChan for included level")
+	}
+}
+
+func TestRunExclusive(t *testing.T) {
+	var running, calls int32
+	started := make(chan struct{})
+	release := make(chan struct{})
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		runExclusive(&running, func() {
+			atomic.AddInt32(&calls, 1)
+			close(started)
+			<-release
+		})
+	}()
+	<-started
+	runExclusive(&running, func() { atomic.AddInt32(&calls, 1) })
+	close(release)
+	wg.Wait()
+	runExclusive(&running, func() { atomic.AddInt32(&calls, 1) })
+	if calls != 2 {
+		t.Errorf("expect overlapping run skipped, got %d calls", calls)
+	}
+}
+
+func TestCheckConfirm_Dropped(t *testing.T) {
+	mux := http.NewServeMux()
+	//节点不认识的交易：回执和交易均为 null
+	mux.HandleFunc("/transactions/", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`null`))
+	})
+	mux.HandleFunc("/transactions/0x01/receipt", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"reverted":false,"meta":{"blockID":"0xb1","blockNumber":100,"blockTimestamp":1597111238,"txID":"0x01"}}`))
+	})
+	mux.HandleFunc("/blocks/0xb1", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"number":100,"id":"0xb1","isTrunk":true}`))
+	})
+	mux.HandleFunc("/blocks/best", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"number":105,"id":"0xbb","isTrunk":true}`))
+	})
+	server := httptest.NewServer(mux)
+	defer server.Close()
+	s, cleanup := newTestService(t, &VechainConfig{ThorNodeUrl: server.URL})
+	defer cleanup()
+	s.Thor = NewThorClient(server.URL)
+	events, cancel := s.Subscribe(EventFilter{Types: []EventType{EventReverted}})
+	defer cancel()
+
+	//一页以上找不到交易但未超时的区块排在前面，之后的区块仍然会被检查
+	var pending []*Block
+	for i := 0; i < ConfirmAmountPerCheck; i++ {
+		pending = append(pending, &Block{Hash: fmt.Sprintf("P%d", i), TxId: fmt.Sprintf("0xp%d", i), State: BlockStatePosted})
+	}
+	for _, v := range chunkBlocks(pending, 50) {
+		if _, err := s.dbEngine.Insert(&v); err != nil {
+			t.Fatal(err)
+		}
+	}
+	blocks := insertTestBlocks(t, s,
+		&Block{Hash: "H9", TxId: "0x09", State: BlockStatePosted},
+		&Block{Hash: "H1", TxId: "0x01", State: BlockStatePosted},
+	)
+	dropped := &Block{CommonModel: CommonModel{Updated: time.Now().Add(-2 * ConfirmDropTimeout)}}
+	if _, err := s.dbEngine.ID(blocks[0].Id).NoAutoTime().Cols("updated").Update(dropped); err != nil {
+		t.Fatal(err)
+	}
+
+	s.checkConfirm()
+	if b := getTestBlock(t, s, blocks[0].Id); b.State != BlockStateReverted {
+		t.Errorf("dropped tx should be reverted, got %d", b.State)
+	}
+	if b := getTestBlock(t, s, blocks[1].Id); b.State != BlockStateIncluded {
+		t.Errorf("block after the first page should be checked, got %d", b.State)
+	}
+	if n, err := s.dbEngine.Where("state=?", BlockStatePosted).Count(&Block{}); err != nil || n != int64(ConfirmAmountPerCheck) {
+		t.Errorf("not found blocks within timeout should stay posted, got %d %v", n, err)
+	}
+	if len(events) != 1 {
+		t.Fatalf("expect 1 reverted event, got %d", len(events))
+	}
+	if event := <-events; event.Block.Hash != "H9" {
+		t.Errorf("unexpected reverted event %+v", event.Block)
+	}
+}
@@ -0,0 +1,167 @@
+package vechain
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/myafeier/log"
+	"gopkg.in/yaml.v2"
+)
+
+// ============开发者凭据============
+// DeveloperId/DeveloperKey 可在运行中轮换，轮换后token管理器重新申请token
+
+const CredentialsPollInterval = 30 * time.Second //凭据文件的检查间隔
+
+//开发者凭据
+type Credentials struct {
+	DeveloperId  string `yaml:"DeveloperId"`
+	DeveloperKey string `yaml:"DeveloperKey"`
+}
+
+//凭据提供者
+type ICredentials interface {
+	//当前凭据
+	Get(ctx context.Context) (*Credentials, error)
+	//下一次轮换时关闭的通道，不支持轮换时返回 nil
+	Changed() <-chan struct{}
+}
+
+func init() {
+	var _ ICredentials = &StaticCredentials{}
+	var _ ICredentials = &MemoryCredentials{}
+	var _ ICredentials = &FileCredentials{}
+}
+
+// StaticCredentials 固定凭据，即配置中的 DeveloperId/DeveloperKey
+type StaticCredentials Credentials
+
+func (self *StaticCredentials) Get(ctx context.Context) (*Credentials, error) {
+	return (*Credentials)(self), nil
+}
+
+func (self *StaticCredentials) Changed() <-chan struct{} {
+	return nil
+}
+
+// MemoryCredentials 可通过 Rotate 轮换的凭据，供接入密钥管理服务时使用
+type MemoryCredentials struct {
+	mutex       sync.RWMutex
+	credentials Credentials
+	changed     chan struct{}
+}
+
+func NewMemoryCredentials(credentials Credentials) *MemoryCredentials {
+	return &MemoryCredentials{credentials: credentials, changed: make(chan struct{})}
+}
+
+func (self *MemoryCredentials) Get(ctx context.Context) (*Credentials, error) {
+	self.mutex.RLock()
+	defer self.mutex.RUnlock()
+	credentials := self.credentials
+	return &credentials, nil
+}
+
+func (self *MemoryCredentials) Changed() <-chan struct{} {
+	self.mutex.RLock()
+	defer self.mutex.RUnlock()
+	return self.changed
+}
+
+// 轮换凭据，与当前凭据相同时忽略
+func (self *MemoryCredentials) Rotate(credentials Credentials) {
+	self.mutex.Lock()
+	defer self.mutex.Unlock()
+	if credentials == self.credentials {
+		return
+	}
+	self.credentials = credentials
+	close(self.changed)
+	self.changed = make(chan struct{})
+	log.Info("developer credentials rotated: %s", credentials.DeveloperId)
+}
+
+// FileCredentials 从 YAML 文件读取凭据，文件修改后自动轮换
+type FileCredentials struct {
+	*MemoryCredentials
+	path    string
+	modTime time.Time
+	stop    chan struct{}
+}
+
+func NewFileCredentials(path string) (credentials *FileCredentials, err error) {
+	credentials = &FileCredentials{path: path, stop: make(chan struct{})}
+	c, modTime, err := credentials.read()
+	if err != nil {
+		return
+	}
+	credentials.MemoryCredentials = NewMemoryCredentials(*c)
+	credentials.modTime = modTime
+	go credentials.watch()
+	return
+}
+
+// 停止检查文件
+func (self *FileCredentials) Close() {
+	close(self.stop)
+}
+
+func (self *FileCredentials) watch() {
+	ticker := time.NewTicker(CredentialsPollInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-self.stop:
+			return
+		case <-ticker.C:
+			self.reload()
+		}
+	}
+}
+
+//文件有修改时重新读取
+func (self *FileCredentials) reload() {
+	info, err := os.Stat(self.path)
+	if err != nil {
+		log.Error("%+v", err.Error())
+		return
+	}
+	if info.ModTime().Equal(self.modTime) {
+		return
+	}
+	c, modTime, err := self.read()
+	if err != nil {
+		return
+	}
+	self.modTime = modTime
+	self.Rotate(*c)
+}
+
+func (self *FileCredentials) read() (credentials *Credentials, modTime time.Time, err error) {
+	info, err := os.Stat(self.path)
+	if err != nil {
+		log.Error("%+v", err.Error())
+		return
+	}
+	modTime = info.ModTime()
+	data, err := ioutil.ReadFile(self.path)
+	if err != nil {
+		log.Error("%+v", err.Error())
+		return
+	}
+	credentials = new(Credentials)
+	err = yaml.Unmarshal(data, credentials)
+	if err != nil {
+		log.Error("%+v", err.Error())
+		return
+	}
+	if credentials.DeveloperId == "" || credentials.DeveloperKey == "" {
+		err = fmt.Errorf("%s: DeveloperId and DeveloperKey are required", self.path)
+		log.Error(err.Error())
+	}
+	return
+}
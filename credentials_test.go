@@ -0,0 +1,67 @@
+package vechain
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestDefaultToken_RotateCredentials(t *testing.T) {
+	var mutex sync.Mutex
+	var forms []*Form
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		form := new(Form)
+		json.NewDecoder(r.Body).Decode(form)
+		mutex.Lock()
+		forms = append(forms, form)
+		mutex.Unlock()
+		w.Write([]byte(`{"code":1,"data":{"token":"` + form.AppId + `","expire":7200}}`))
+	}))
+	defer server.Close()
+
+	credentials := NewMemoryCredentials(Credentials{DeveloperId: "id1", DeveloperKey: "key1"})
+	token := NewDefaultToken(&VechainConfig{SiteUrl: server.URL + "/", Credentials: credentials})
+	defer token.Close()
+
+	tokenStr, err := token.GetToken(context.Background())
+	if err != nil || tokenStr != "id1" {
+		t.Fatalf("get token: %q %v", tokenStr, err)
+	}
+
+	credentials.Rotate(Credentials{DeveloperId: "id2", DeveloperKey: "key2"})
+	deadline := time.Now().Add(2 * time.Second)
+	for time.Now().Before(deadline) {
+		tokenStr, err = token.GetToken(context.Background())
+		if tokenStr == "id2" {
+			break
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	if tokenStr != "id2" {
+		t.Fatalf("token not refreshed after rotation: %q %v", tokenStr, err)
+	}
+
+	mutex.Lock()
+	defer mutex.Unlock()
+	if len(forms) != 2 {
+		t.Fatalf("expect 2 token requests, got %d", len(forms))
+	}
+	if forms[0].Nonce == forms[1].Nonce {
+		t.Errorf("nonce reused: %s", forms[0].Nonce)
+	}
+	if forms[1].Signature != sign(mustParseInt(t, forms[1].Timestamp), forms[1].Nonce, &Credentials{DeveloperId: "id2", DeveloperKey: "key2"}) {
+		t.Errorf("signature not made with rotated key")
+	}
+}
+
+func mustParseInt(t *testing.T, s string) (i int64) {
+	err := json.Unmarshal([]byte(s), &i)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return
+}
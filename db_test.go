@@ -0,0 +1,58 @@
+package vechain
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	_ "github.com/mattn/go-sqlite3"
+	"xorm.io/xorm"
+)
+
+//基于临时 sqlite 文件的服务，不启动守护进程，cleanup 删除数据库文件
+func newTestService(t *testing.T, cfg *VechainConfig) (s *Service, cleanup func()) {
+	dir, err := ioutil.TempDir("", "vechain")
+	if err != nil {
+		t.Fatal(err)
+	}
+	engine, err := xorm.NewEngine("sqlite3", filepath.Join(dir, "test.db")+"?_busy_timeout=5000")
+	if err != nil {
+		t.Fatal(err)
+	}
+	initTable(engine.NewSession())
+	cfg.SetDefaults()
+	s = &Service{
+		dbEngine:      engine,
+		config:        cfg,
+		CommandChan:   make(chan ICommand, 100),
+		SuccessChan:   make(chan *Block, 100),
+		vidPoolSignal: make(chan struct{}, 1),
+		Accounts:      NewAccountRegistry(),
+	}
+	s.Accounts.Register(DefaultAccountKey, "U0")
+	cleanup = func() {
+		engine.Close()
+		os.RemoveAll(dir)
+	}
+	return
+}
+
+//插入区块，返回插入后的区块
+func insertTestBlocks(t *testing.T, s *Service, blocks ...*Block) []*Block {
+	for _, v := range blocks {
+		if _, err := s.dbEngine.Insert(v); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return blocks
+}
+
+func getTestBlock(t *testing.T, s *Service, id int64) *Block {
+	b := new(Block)
+	has, err := s.dbEngine.ID(id).Get(b)
+	if err != nil || !has {
+		t.Fatalf("block %d not found: %v", id, err)
+	}
+	return b
+}
@@ -0,0 +1,104 @@
+package vechain
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// ============环境============
+// 内置测试网/主网的接口地址、浏览器地址和节点地址，切换环境只需修改 Environment
+// 节点地址可以换成自建节点，启动时按创世区块 id 确认节点属于所选网络
+
+const (
+	EnvironmentTestnet = "testnet" //测试网
+	EnvironmentMainnet = "mainnet" //主网
+	EnvironmentCustom  = "custom"  //自定义，各地址自行配置（默认）
+
+	ThorNetworkCheckTimeout = 30 * time.Second //启动时确认节点网络的超时时间
+)
+
+type EnvironmentProfile struct {
+	SiteUrl     string
+	ExploreLink string
+	ThorNodeUrl string
+	GenesisId   string //节点 /blocks/0 返回的创世区块 id
+}
+
+var Environments = map[string]EnvironmentProfile{
+	EnvironmentTestnet: {
+		SiteUrl:     "https://developer.vetoolchain.cn/api/",
+		ExploreLink: "https://insight.vecha.in/#/test/%s",
+		ThorNodeUrl: "https://testnet.vechain.org",
+		GenesisId:   "0x000000000b2bce3c70bc649a02749e8687721b09ed2e15997f466536b20bb127",
+	},
+	EnvironmentMainnet: {
+		SiteUrl:     "https://developer.vetoolchain.com/api/",
+		ExploreLink: "https://insight.vecha.in/#/main/%s",
+		ThorNodeUrl: "https://mainnet.vechain.org",
+		GenesisId:   "0x00000000851caf3cfdb6e899cf5958bfb1ac3413d346d43539627e6be7ec1b4a",
+	},
+}
+
+//当前环境的预设，自定义环境返回 false
+func (self *VechainConfig) profile() (profile EnvironmentProfile, ok bool) {
+	profile, ok = Environments[self.Environment]
+	return
+}
+
+//用环境预设补全未配置的地址
+func (self *VechainConfig) applyEnvironment() {
+	profile, ok := self.profile()
+	if !ok {
+		return
+	}
+	if self.SiteUrl == "" {
+		self.SiteUrl = profile.SiteUrl
+	}
+	if self.ExploreLink == "" {
+		self.ExploreLink = profile.ExploreLink
+	}
+	if self.ThorNodeUrl == "" {
+		self.ThorNodeUrl = profile.ThorNodeUrl
+	}
+}
+
+//非自定义环境下，显式配置的地址必须与预设一致
+func (self *VechainConfig) checkEnvironment() (errs []error) {
+	switch self.Environment {
+	case "", EnvironmentCustom:
+		return
+	}
+	profile, ok := self.profile()
+	if !ok {
+		errs = append(errs, fmt.Errorf("Environment %q is invalid, use %q, %q or %q", self.Environment, EnvironmentTestnet, EnvironmentMainnet, EnvironmentCustom))
+		return
+	}
+	check := func(field, value, expect string) {
+		if value != expect {
+			errs = append(errs, fmt.Errorf("%s %q does not match Environment %s (%q), remove it or use Environment %q", field, value, self.Environment, expect, EnvironmentCustom))
+		}
+	}
+	check("SiteUrl", self.SiteUrl, profile.SiteUrl)
+	check("ExploreLink", self.ExploreLink, profile.ExploreLink)
+	return
+}
+
+//非自定义环境下确认节点属于所选网络：节点的创世区块 id 必须与预设一致
+//  节点地址可以是自建节点，不要求与预设地址相同
+func (self *VechainConfig) checkThorNetwork(ctx context.Context, thor *ThorClient) (err error) {
+	profile, ok := self.profile()
+	if !ok || thor == nil || profile.GenesisId == "" {
+		return
+	}
+	genesis, err := thor.GetBlock(ctx, "0")
+	if err != nil {
+		err = fmt.Errorf("ThorNodeUrl %q: get genesis block: %w", self.ThorNodeUrl, err)
+		return
+	}
+	if !strings.EqualFold(genesis.Id, profile.GenesisId) {
+		err = fmt.Errorf("ThorNodeUrl %q is not a %s node: genesis block %s, expect %s", self.ThorNodeUrl, self.Environment, genesis.Id, profile.GenesisId)
+	}
+	return
+}
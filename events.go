@@ -0,0 +1,142 @@
+package vechain
+
+import (
+	"sync"
+	"time"
+
+	"github.com/myafeier/log"
+)
+
+// ============事件订阅============
+// 抢占、上链、确认、命令失败等结果以事件推送给订阅者，供监控面板等实时展示
+
+const EventBufferSize = 1000 //每个订阅者的事件缓冲，订阅者处理不及时时丢弃事件
+
+type EventType string
+
+const (
+	EventOccupied      EventType = "occupied"       //vid 抢占成功
+	EventPosted        EventType = "posted"         //ToolChain 已受理上链
+	EventSucceeded     EventType = "succeeded"      //达到 FinalityLevel，即推送到 SuccessChan、触发观察者
+	EventReverted      EventType = "reverted"       //上链交易被回滚
+	EventCommandFailed EventType = "command_failed" //命令执行失败
+)
+
+// Event 事件，区块事件每个区块一条，命令失败事件每个命令一条
+type Event struct {
+	Type      EventType `json:"type"`
+	Time      time.Time `json:"time"`
+	CommandId int64     `json:"command_id,omitempty"`
+	Account   string    `json:"account"`
+	Block     *Block    `json:"block,omitempty"`  //区块事件的区块
+	Hashes    []string  `json:"hashes,omitempty"` //命令失败事件中命令包含的 hash
+	Error     string    `json:"error,omitempty"`
+}
+
+// EventFilter 订阅条件，各字段为空时不过滤
+type EventFilter struct {
+	Types   []EventType
+	Account string
+	Hashes  []string
+}
+
+func (self *EventFilter) match(event *Event) bool {
+	if len(self.Types) > 0 {
+		matched := false
+		for _, v := range self.Types {
+			if v == event.Type {
+				matched = true
+				break
+			}
+		}
+		if !matched {
+			return false
+		}
+	}
+	if self.Account != "" && accountKey(self.Account) != event.Account {
+		return false
+	}
+	if len(self.Hashes) > 0 {
+		hashes := event.Hashes
+		if event.Block != nil {
+			hashes = []string{event.Block.Hash}
+		}
+		for _, v := range self.Hashes {
+			for _, vv := range hashes {
+				if v == vv {
+					return true
+				}
+			}
+		}
+		return false
+	}
+	return true
+}
+
+type subscription struct {
+	filter EventFilter
+	events chan Event
+}
+
+type eventHub struct {
+	mutex         sync.RWMutex
+	subscriptions map[*subscription]struct{}
+}
+
+// 订阅事件，调用 cancel 后通道关闭
+func (s *Service) Subscribe(filter EventFilter) (events <-chan Event, cancel func()) {
+	sub := &subscription{filter: filter, events: make(chan Event, EventBufferSize)}
+	s.events.mutex.Lock()
+	if s.events.subscriptions == nil {
+		s.events.subscriptions = make(map[*subscription]struct{})
+	}
+	s.events.subscriptions[sub] = struct{}{}
+	s.events.mutex.Unlock()
+
+	var once sync.Once
+	cancel = func() {
+		once.Do(func() {
+			s.events.mutex.Lock()
+			delete(s.events.subscriptions, sub)
+			s.events.mutex.Unlock()
+			close(sub.events)
+		})
+	}
+	return sub.events, cancel
+}
+
+func (s *Service) publish(event Event) {
+	event.Time = time.Now()
+	event.Account = accountKey(event.Account)
+	s.events.mutex.RLock()
+	defer s.events.mutex.RUnlock()
+	for sub := range s.events.subscriptions {
+		if !sub.filter.match(&event) {
+			continue
+		}
+		select {
+		case sub.events <- event:
+		default:
+			log.Warn("event subscriber is full, drop %s event", event.Type)
+		}
+	}
+}
+
+//区块事件，区块复制一份，避免之后的修改影响订阅者
+func (s *Service) publishBlocks(eventType EventType, commandId int64, blocks []*Block) {
+	for _, v := range blocks {
+		b := *v
+		if b.TxId != "" && s.config != nil {
+			b.ExplorUrl = BlockChainExploreLink(b.TxId, s.config)
+		}
+		s.publish(Event{Type: eventType, CommandId: commandId, Account: b.Account, Block: &b})
+	}
+}
+
+func (s *Service) publishCommandFailed(commandId int64, account string, blocks []*Block, err string) {
+	event := Event{Type: EventCommandFailed, CommandId: commandId, Account: account, Error: err}
+	for _, v := range blocks {
+		event.Hashes = append(event.Hashes, v.Hash)
+	}
+	s.publish(event)
+}
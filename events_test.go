@@ -0,0 +1,45 @@
+package vechain
+
+import (
+	"testing"
+	"time"
+)
+
+func TestSubscribe(t *testing.T) {
+	s := &Service{config: &VechainConfig{ExploreLink: "https://explore/%s"}}
+	all, cancelAll := s.Subscribe(EventFilter{})
+	defer cancelAll()
+	posted, cancelPosted := s.Subscribe(EventFilter{Types: []EventType{EventPosted}, Account: "brand-a"})
+	failed, cancelFailed := s.Subscribe(EventFilter{Hashes: []string{"H2"}})
+	defer cancelFailed()
+
+	b := &Block{Hash: "H1", TxId: "0x01", Account: "brand-a"}
+	s.publishBlocks(EventPosted, 1, []*Block{b})
+	s.publishBlocks(EventPosted, 2, []*Block{{Hash: "H3"}})
+	s.publishCommandFailed(3, "", []*Block{{Hash: "H2"}}, "FAIL")
+	b.TxId = "0x02"
+
+	event := <-posted
+	if event.Block.Hash != "H1" || event.Block.TxId != "0x01" || event.Block.ExplorUrl != "https://explore/0x01" || event.CommandId != 1 {
+		t.Errorf("unexpected posted event: %+v %+v", event, *event.Block)
+	}
+	event = <-failed
+	if event.Type != EventCommandFailed || event.Account != DefaultAccountKey || event.Error != "FAIL" {
+		t.Errorf("unexpected failed event: %+v", event)
+	}
+	if len(all) != 3 || len(posted) != 0 || len(failed) != 0 {
+		t.Errorf("unexpected event count: all:%d posted:%d failed:%d", len(all), len(posted), len(failed))
+	}
+
+	cancelPosted()
+	cancelPosted()
+	s.publishBlocks(EventPosted, 4, []*Block{b})
+	select {
+	case _, ok := <-posted:
+		if ok {
+			t.Error("expect channel closed after cancel")
+		}
+	case <-time.After(time.Second):
+		t.Error("expect channel closed after cancel")
+	}
+}
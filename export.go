@@ -0,0 +1,215 @@
+package vechain
+
+import (
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+	"time"
+
+	"github.com/myafeier/log"
+)
+
+// ============导出============
+// 按条件分批读取 vechain_block 并逐条写出，不会一次加载全部记录
+
+const (
+	ExportFormatCSV   = "csv"
+	ExportFormatJSONL = "jsonl"
+	ExportFormatJSON  = "json" //JSON 数组
+
+	ExportBatchSize = 1000 //每次从数据库读取的记录数
+)
+
+// ExportFilter 导出条件，各字段为空时不过滤，时间范围为 [From, To)
+type ExportFilter struct {
+	States      []BlockState
+	Account     string //子账户标识，default 为默认账户
+	CreatedFrom time.Time
+	CreatedTo   time.Time
+	UpdatedFrom time.Time
+	UpdatedTo   time.Time
+}
+
+// ExportRecord 导出的一行
+type ExportRecord struct {
+	Hash           string `json:"hash"`
+	Vid            string `json:"vid"`
+	TxId           string `json:"tx_id"`
+	ClauseIndex    string `json:"clause_index"`
+	State          string `json:"state"`
+	Account        string `json:"account"`
+	BlockNumber    int64  `json:"block_number"`
+	BlockTimestamp int64  `json:"block_timestamp"`
+	Confirmations  int64  `json:"confirmations"`
+	ExploreUrl     string `json:"explore_url"`
+	Created        string `json:"created"`
+	Updated        string `json:"updated"`
+}
+
+var exportColumns = []string{"hash", "vid", "tx_id", "clause_index", "state", "account", "block_number", "block_timestamp", "confirmations", "explore_url", "created", "updated"}
+
+func (self *ExportRecord) values() []string {
+	return []string{
+		self.Hash, self.Vid, self.TxId, self.ClauseIndex, self.State, self.Account,
+		strconv.FormatInt(self.BlockNumber, 10), strconv.FormatInt(self.BlockTimestamp, 10), strconv.FormatInt(self.Confirmations, 10),
+		self.ExploreUrl, self.Created, self.Updated,
+	}
+}
+
+func newExportRecord(b *Block, config *VechainConfig) *ExportRecord {
+	r := &ExportRecord{
+		Hash:           b.Hash,
+		Vid:            b.Vid,
+		TxId:           b.TxId,
+		ClauseIndex:    b.ClauseIndex,
+		State:          b.State.Name(),
+		Account:        accountKey(b.Account),
+		BlockNumber:    b.BlockNumber,
+		BlockTimestamp: b.BlockTimestamp,
+		Confirmations:  b.Confirmations,
+		Created:        b.Created.Format(time.RFC3339),
+		Updated:        b.Updated.Format(time.RFC3339),
+	}
+	if b.TxId != "" {
+		r.ExploreUrl = BlockChainExploreLink(b.TxId, config)
+	}
+	return r
+}
+
+type exportWriter interface {
+	write(r *ExportRecord) error
+	close() error
+}
+
+func newExportWriter(w io.Writer, format string) (exportWriter, error) {
+	switch format {
+	case ExportFormatCSV:
+		return &csvExportWriter{writer: csv.NewWriter(w)}, nil
+	case ExportFormatJSONL:
+		return &jsonExportWriter{w: w, encoder: json.NewEncoder(w)}, nil
+	case ExportFormatJSON:
+		return &jsonExportWriter{w: w, encoder: json.NewEncoder(w), array: true}, nil
+	}
+	return nil, fmt.Errorf("unsupported export format: %s", format)
+}
+
+type csvExportWriter struct {
+	writer *csv.Writer
+	header bool
+}
+
+func (self *csvExportWriter) write(r *ExportRecord) error {
+	if !self.header {
+		self.header = true
+		if err := self.writer.Write(exportColumns); err != nil {
+			return err
+		}
+	}
+	return self.writer.Write(r.values())
+}
+
+func (self *csvExportWriter) close() error {
+	if !self.header { //没有记录时也输出表头
+		self.header = true
+		if err := self.writer.Write(exportColumns); err != nil {
+			return err
+		}
+	}
+	self.writer.Flush()
+	return self.writer.Error()
+}
+
+//JSONL 每行一条；JSON 数组每个元素一行
+type jsonExportWriter struct {
+	w       io.Writer
+	encoder *json.Encoder
+	array   bool
+	n       int
+}
+
+func (self *jsonExportWriter) write(r *ExportRecord) (err error) {
+	if self.array {
+		sep := ","
+		if self.n == 0 {
+			sep = "["
+		}
+		if _, err = io.WriteString(self.w, sep); err != nil {
+			return
+		}
+	}
+	self.n++
+	return self.encoder.Encode(r)
+}
+
+func (self *jsonExportWriter) close() (err error) {
+	if !self.array {
+		return
+	}
+	if self.n == 0 {
+		_, err = io.WriteString(self.w, "[]\n")
+		return
+	}
+	_, err = io.WriteString(self.w, "]\n")
+	return
+}
+
+// 按条件导出区块到 w，返回导出的条数
+func (s *Service) Export(ctx context.Context, w io.Writer, format string, filter ExportFilter) (n int, err error) {
+	writer, err := newExportWriter(w, format)
+	if err != nil {
+		return
+	}
+	var lastId int64
+	for {
+		if err = ctx.Err(); err != nil {
+			return
+		}
+		var blocks []*Block
+		session := s.dbEngine.NewSession()
+		session.Where("id>?", lastId)
+		if len(filter.States) > 0 {
+			session.In("state", filter.States)
+		}
+		if filter.Account != "" {
+			account := filter.Account
+			if account == DefaultAccountKey {
+				account = ""
+			}
+			session.And("account=?", account)
+		}
+		if !filter.CreatedFrom.IsZero() {
+			session.And("created>=?", filter.CreatedFrom)
+		}
+		if !filter.CreatedTo.IsZero() {
+			session.And("created<?", filter.CreatedTo)
+		}
+		if !filter.UpdatedFrom.IsZero() {
+			session.And("updated>=?", filter.UpdatedFrom)
+		}
+		if !filter.UpdatedTo.IsZero() {
+			session.And("updated<?", filter.UpdatedTo)
+		}
+		err = session.Asc("id").Limit(ExportBatchSize).Find(&blocks)
+		session.Close()
+		if err != nil {
+			log.Error("%+v", err.Error())
+			return
+		}
+		for _, v := range blocks {
+			err = writer.write(newExportRecord(v, s.config))
+			if err != nil {
+				return
+			}
+			n++
+		}
+		if len(blocks) < ExportBatchSize {
+			break
+		}
+		lastId = blocks[len(blocks)-1].Id
+	}
+	err = writer.close()
+	return
+}
@@ -0,0 +1,75 @@
+package vechain
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestExportWriter(t *testing.T) {
+	config := &VechainConfig{ExploreLink: "https://explore/%s"}
+	created := time.Date(2020, 8, 1, 8, 0, 0, 0, time.UTC)
+	blocks := []*Block{
+		{CommonModel: CommonModel{Created: created, Updated: created}, Hash: "H1", Vid: "V1", TxId: "0x01", ClauseIndex: "0", State: BlockStateFinalized, Account: "brand-a", BlockNumber: 10},
+		{CommonModel: CommonModel{Created: created, Updated: created}, Hash: "H2", Vid: "V2", State: BlockStateToPost},
+	}
+	export := func(format string, blocks []*Block) string {
+		buf := new(bytes.Buffer)
+		w, err := newExportWriter(buf, format)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, v := range blocks {
+			if err = w.write(newExportRecord(v, config)); err != nil {
+				t.Fatal(err)
+			}
+		}
+		if err = w.close(); err != nil {
+			t.Fatal(err)
+		}
+		return buf.String()
+	}
+
+	csv := export(ExportFormatCSV, blocks)
+	expect := "hash,vid,tx_id,clause_index,state,account,block_number,block_timestamp,confirmations,explore_url,created,updated\n" +
+		"H1,V1,0x01,0,finalized,brand-a,10,0,0,https://explore/0x01,2020-08-01T08:00:00Z,2020-08-01T08:00:00Z\n" +
+		"H2,V2,,,to_post,default,0,0,0,,2020-08-01T08:00:00Z,2020-08-01T08:00:00Z\n"
+	if csv != expect {
+		t.Errorf("unexpected csv:\n%s", csv)
+	}
+	if csv = export(ExportFormatCSV, nil); !strings.HasPrefix(csv, "hash,") {
+		t.Errorf("expect header for empty csv, got %q", csv)
+	}
+
+	lines := strings.Split(strings.TrimSpace(export(ExportFormatJSONL, blocks)), "\n")
+	if len(lines) != 2 || !strings.Contains(lines[0], `"explore_url":"https://explore/0x01"`) {
+		t.Errorf("unexpected jsonl: %v", lines)
+	}
+
+	var records []*ExportRecord
+	if err := json.Unmarshal([]byte(export(ExportFormatJSON, blocks)), &records); err != nil || len(records) != 2 || records[1].Hash != "H2" {
+		t.Errorf("unexpected json: %v %v", records, err)
+	}
+	if err := json.Unmarshal([]byte(export(ExportFormatJSON, nil)), &records); err != nil || len(records) != 0 {
+		t.Errorf("expect empty json array, got %v %v", records, err)
+	}
+
+	if _, err := newExportWriter(new(bytes.Buffer), "xlsx"); err == nil {
+		t.Error("expect error for unsupported format")
+	}
+}
+
+func TestParseBlockState(t *testing.T) {
+	for name, expect := range map[string]BlockState{"finalized": BlockStateFinalized, "Posted": BlockStatePosted, "6": BlockStateReverted} {
+		if state, err := ParseBlockState(name); err != nil || state != expect {
+			t.Errorf("%s: expect %d, got %d %v", name, expect, state, err)
+		}
+	}
+	for _, name := range []string{"", "done", "7"} {
+		if _, err := ParseBlockState(name); err == nil {
+			t.Errorf("%s: expect error", name)
+		}
+	}
+}
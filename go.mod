@@ -5,6 +5,12 @@ go 1.13
 require (
 	github.com/go-sql-driver/mysql v1.4.1
 	github.com/go-xorm/xorm v0.7.9
+	github.com/golang/protobuf v1.3.5
+	github.com/jung-kurt/gofpdf v1.16.2
+	github.com/mattn/go-sqlite3 v1.10.0
 	github.com/myafeier/log v1.0.0
+	github.com/skip2/go-qrcode v0.0.0-20200617195104-da1b6568686e
+	google.golang.org/grpc v1.27.1
+	gopkg.in/yaml.v2 v2.4.0
 	xorm.io/xorm v1.0.1
 )
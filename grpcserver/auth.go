@@ -0,0 +1,58 @@
+package grpcserver
+
+import (
+	"context"
+	"strings"
+
+	"github.com/myafeier/vechain"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+// ============认证============
+// 与 HTTP 接口共用配置 ApiKeys，客户端在 metadata 中携带 x-api-key: <key> 或 authorization: Bearer <key>
+
+// 校验 api key 的 grpc.ServerOption，创建 grpc.Server 时传入
+//  s := grpc.NewServer(grpcserver.ServerOptions(vechain.Daemon)...)
+func ServerOptions(service *vechain.Service) []grpc.ServerOption {
+	return []grpc.ServerOption{
+		grpc.UnaryInterceptor(UnaryAuthInterceptor(service)),
+		grpc.StreamInterceptor(StreamAuthInterceptor(service)),
+	}
+}
+
+// 校验 api key 的一元拦截器，已有拦截器时可与其组合使用
+func UnaryAuthInterceptor(service *vechain.Service) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, request interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		if err := authenticate(ctx, service); err != nil {
+			return nil, err
+		}
+		return handler(ctx, request)
+	}
+}
+
+// 校验 api key 的流拦截器
+func StreamAuthInterceptor(service *vechain.Service) grpc.StreamServerInterceptor {
+	return func(srv interface{}, stream grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		if err := authenticate(stream.Context(), service); err != nil {
+			return err
+		}
+		return handler(srv, stream)
+	}
+}
+
+func authenticate(ctx context.Context, service *vechain.Service) error {
+	md, _ := metadata.FromIncomingContext(ctx)
+	var key string
+	if v := md.Get("x-api-key"); len(v) > 0 {
+		key = v[0]
+	} else if v := md.Get("authorization"); len(v) > 0 {
+		key = strings.TrimPrefix(v[0], "Bearer ")
+	}
+	if key == "" || !service.ValidApiKey(key) {
+		return status.Error(codes.Unauthenticated, "invalid api key")
+	}
+	return nil
+}
@@ -0,0 +1,129 @@
+// Package grpcserver 以 gRPC 提供 vechain.Service，接口定义见 vechainpb/vechain.proto
+//
+//  s := grpc.NewServer(grpcserver.ServerOptions(vechain.Daemon)...)
+//  grpcserver.Register(s, vechain.Daemon)
+//  s.Serve(lis)
+//
+// ServerOptions 按配置 ApiKeys 校验客户端的 api key；不使用时服务不做认证，只能部署在可信网络内
+package grpcserver
+
+import (
+	"context"
+	"errors"
+	"strings"
+
+	"github.com/myafeier/vechain"
+	"github.com/myafeier/vechain/vechainpb"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// Server 实现 vechainpb.VechainServer
+type Server struct {
+	service *vechain.Service
+}
+
+func NewServer(service *vechain.Service) *Server {
+	return &Server{service: service}
+}
+
+// 在 grpc.Server 上注册 Vechain 服务
+func Register(grpcServer *grpc.Server, service *vechain.Service) *Server {
+	s := NewServer(service)
+	vechainpb.RegisterVechainServer(grpcServer, s)
+	return s
+}
+
+func (s *Server) Submit(ctx context.Context, request *vechainpb.SubmitRequest) (*vechainpb.SubmitResponse, error) {
+	if err := vechain.ValidateHashes(request.Hashes); err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+	account := request.Account
+	if account == "" {
+		account = vechain.DefaultAccountKey
+	}
+	if _, err := s.service.Accounts.Uid(account); err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+	added, err := s.service.SubmitForAccount(account, request.Hashes)
+	var conflict *vechain.AccountConflictError
+	if errors.As(err, &conflict) { //其余 hash 已提交
+		return &vechainpb.SubmitResponse{Accepted: int32(added), Conflicts: conflict.Hashes}, nil
+	}
+	if err != nil {
+		return nil, status.Error(codes.Internal, err.Error())
+	}
+	return &vechainpb.SubmitResponse{Accepted: int32(added)}, nil
+}
+
+func (s *Server) GetBlock(ctx context.Context, request *vechainpb.GetBlockRequest) (*vechainpb.Block, error) {
+	if request.Hash == "" {
+		return nil, status.Error(codes.InvalidArgument, "hash is required")
+	}
+	b, err := s.service.GetBlockInfoByUuid(request.Hash)
+	if err != nil {
+		if errors.Is(err, vechain.ErrBlockNotFound) {
+			return nil, status.Error(codes.NotFound, err.Error())
+		}
+		return nil, status.Error(codes.Internal, err.Error())
+	}
+	return toBlock(b), nil
+}
+
+// 推送达到 FinalityLevel 的区块，即 vechain.EventSucceeded 事件
+func (s *Server) WatchBlocks(request *vechainpb.WatchBlocksRequest, stream vechainpb.Vechain_WatchBlocksServer) error {
+	events, cancel := s.service.Subscribe(vechain.EventFilter{Types: []vechain.EventType{vechain.EventSucceeded}, Hashes: request.Hashes})
+	defer cancel()
+	for {
+		select {
+		case <-stream.Context().Done():
+			return nil
+		case event := <-events:
+			err := stream.Send(&vechainpb.BlockEvent{Hash: event.Block.Hash, Vid: event.Block.Vid, TxId: event.Block.TxId, ExploreUrl: event.Block.ExplorUrl})
+			if err != nil {
+				return err
+			}
+		}
+	}
+}
+
+func (s *Server) ListCommands(ctx context.Context, request *vechainpb.ListCommandsRequest) (*vechainpb.ListCommandsResponse, error) {
+	commands, err := s.service.ListCommands(vechain.CommandState(strings.ToUpper(request.State)), int(request.Limit))
+	if err != nil {
+		return nil, status.Error(codes.Internal, err.Error())
+	}
+	response := new(vechainpb.ListCommandsResponse)
+	for _, v := range commands {
+		response.Commands = append(response.Commands, &vechainpb.Command{
+			Id:      v.Id,
+			Cmd:     v.Cmd,
+			State:   string(v.State),
+			Error:   v.Error,
+			Account: v.Account,
+			Created: v.Created.Unix(),
+			Updated: v.Updated.Unix(),
+		})
+	}
+	return response, nil
+}
+
+func toBlock(b *vechain.Block) *vechainpb.Block {
+	return &vechainpb.Block{
+		Id:               b.Id,
+		Hash:             b.Hash,
+		Vid:              b.Vid,
+		TxId:             b.TxId,
+		ClauseIndex:      b.ClauseIndex,
+		State:            int32(b.State),
+		CurrentCommandId: b.CurrentCommandId,
+		Account:          b.Account,
+		BlockNumber:      b.BlockNumber,
+		BlockTimestamp:   b.BlockTimestamp,
+		Reverted:         b.Reverted,
+		Confirmations:    b.Confirmations,
+		ExploreUrl:       b.ExplorUrl,
+		Created:          b.Created.Unix(),
+		Updated:          b.Updated.Unix(),
+	}
+}
@@ -0,0 +1,129 @@
+package grpcserver
+
+import (
+	"context"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	_ "github.com/mattn/go-sqlite3"
+	"github.com/myafeier/vechain"
+	"github.com/myafeier/vechain/vechainpb"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+	"xorm.io/xorm"
+)
+
+//基于 sqlite 和 bufconn 的服务，平台接口一律返回错误，命令执行失败不影响本地记录
+func newTestClient(t *testing.T) (client vechainpb.VechainClient, cleanup func()) {
+	api := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"code":100001,"message":"param error"}`))
+	}))
+	dir, err := ioutil.TempDir("", "vechain-grpc")
+	if err != nil {
+		t.Fatal(err)
+	}
+	engine, err := xorm.NewEngine("sqlite3", filepath.Join(dir, "test.db")+"?_busy_timeout=5000")
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = vechain.InitService(engine, &vechain.VechainConfig{
+		SiteUrl:             api.URL + "/",
+		ExploreLink:         "https://explore/%s",
+		DeveloperId:         "id",
+		DeveloperKey:        "key",
+		UserIdOfYuanZhiLian: "U0",
+		SubAccounts:         map[string]string{"brand-b": "U1"},
+		ApiKeys:             []string{"secret"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	lis := bufconn.Listen(1 << 20)
+	server := grpc.NewServer(ServerOptions(vechain.Daemon)...)
+	Register(server, vechain.Daemon)
+	go server.Serve(lis)
+	conn, err := grpc.Dial("bufnet", grpc.WithInsecure(), grpc.WithContextDialer(func(ctx context.Context, addr string) (net.Conn, error) {
+		return lis.Dial()
+	}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	client = vechainpb.NewVechainClient(conn)
+	cleanup = func() {
+		conn.Close()
+		server.Stop()
+		api.Close()
+		engine.Close()
+		os.RemoveAll(dir)
+	}
+	return
+}
+
+func TestServer(t *testing.T) {
+	client, cleanup := newTestClient(t)
+	defer cleanup()
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	//未携带或携带错误的 api key
+	for _, c := range []context.Context{ctx, metadata.AppendToOutgoingContext(ctx, "x-api-key", "other")} {
+		_, err := client.Submit(c, &vechainpb.SubmitRequest{Hashes: []string{"H1"}})
+		if status.Code(err) != codes.Unauthenticated {
+			t.Errorf("expect Unauthenticated, got %v", err)
+		}
+	}
+	stream, err := client.WatchBlocks(ctx, &vechainpb.WatchBlocksRequest{})
+	if err == nil {
+		_, err = stream.Recv()
+	}
+	if status.Code(err) != codes.Unauthenticated {
+		t.Errorf("stream: expect Unauthenticated, got %v", err)
+	}
+
+	ctx = metadata.AppendToOutgoingContext(ctx, "authorization", "Bearer secret")
+	if _, err = client.Submit(ctx, &vechainpb.SubmitRequest{}); status.Code(err) != codes.InvalidArgument {
+		t.Errorf("expect InvalidArgument, got %v", err)
+	}
+	submitted, err := client.Submit(ctx, &vechainpb.SubmitRequest{Hashes: []string{"H1", "H2"}})
+	if err != nil || submitted.Accepted != 2 {
+		t.Fatalf("submit: %v %v", submitted, err)
+	}
+
+	block, err := client.GetBlock(ctx, &vechainpb.GetBlockRequest{Hash: "H1"})
+	if err != nil || block.Hash != "H1" || block.Vid == "" {
+		t.Errorf("get block: %v %v", block, err)
+	}
+	if _, err = client.GetBlock(ctx, &vechainpb.GetBlockRequest{Hash: "H3"}); status.Code(err) != codes.NotFound {
+		t.Errorf("expect NotFound, got %v", err)
+	}
+
+	commands, err := client.ListCommands(ctx, &vechainpb.ListCommandsRequest{Limit: 10})
+	if err != nil || len(commands.Commands) != 1 || commands.Commands[0].Cmd != vechain.Command_Occupy_Vid {
+		t.Errorf("list commands: %v %v", commands, err)
+	}
+
+	for _, hash := range []string{" ", strings.Repeat("a", vechain.HashMaxLength+1)} {
+		if _, err = client.Submit(ctx, &vechainpb.SubmitRequest{Hashes: []string{"H5", hash}}); status.Code(err) != codes.InvalidArgument {
+			t.Errorf("%q: expect InvalidArgument, got %v", hash, err)
+		}
+	}
+	//已提交过的 H1 不计入，已由其他账户提交的 H3 列为冲突
+	if _, err = client.Submit(ctx, &vechainpb.SubmitRequest{Account: "brand-b", Hashes: []string{"H3"}}); err != nil {
+		t.Fatal(err)
+	}
+	submitted, err = client.Submit(ctx, &vechainpb.SubmitRequest{Hashes: []string{"H1", "H3", "H4"}})
+	if err != nil || submitted.Accepted != 1 || len(submitted.Conflicts) != 1 || submitted.Conflicts[0] != "H3" {
+		t.Errorf("submit with conflict: %v %v", submitted, err)
+	}
+}
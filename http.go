@@ -0,0 +1,290 @@
+package vechain
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/myafeier/log"
+)
+
+// ============HTTP 接口============
+// 供非 Go 项目使用，所有接口返回 JSON，出错时返回 {"error":"..."}
+//  POST /submissions            提交 hash，{"account":"","hashes":["0x.."]}；部分 hash 已由其他账户提交时返回 409，conflicts 列出这些 hash，其余照常提交
+//  GET  /blocks/{hash}          区块信息，含浏览器地址
+//  GET  /blocks/{hash}/qr       二维码，参数 format（png/svg）、size、level（L/M/Q/H）、target（scan/vid/explore）
+//  GET  /commands/{id}          命令及其区块
+//  POST /commands/{id}/retry    重新执行未成功的命令
+//  GET  /events                 以 SSE 推送事件，可用 type（逗号分隔）、account、hash（可重复）过滤
+
+const (
+	MaxRequestBodySize = 10 << 20         //请求体上限 10M
+	SSEKeepAlive       = 30 * time.Second //SSE 心跳间隔，避免代理断开空闲连接
+)
+
+//提交请求
+type SubmissionRequest struct {
+	Account string   `json:"account"` //子账户标识，空为默认账户
+	Hashes  []string `json:"hashes"`
+}
+
+//命令及其区块
+type CommandDetail struct {
+	*CommandModel `json:",inline"`
+	Blocks        []*Block `json:"blocks"`
+}
+
+// 返回 HTTP 接口的 handler，需在 InitService 之后调用，以 ApiKeys 鉴权
+func (s *Service) HTTPHandler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/submissions", s.handleSubmissions)
+	mux.HandleFunc("/blocks/", s.handleBlock)
+	mux.HandleFunc("/commands/", s.handleCommand)
+	mux.HandleFunc("/events", s.handleEvents)
+	return s.authenticate(mux)
+}
+
+func (s *Service) authenticate(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		key := r.Header.Get("X-Api-Key")
+		if key == "" {
+			key = strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")
+		}
+		if key == "" || !s.ValidApiKey(key) {
+			writeError(w, http.StatusUnauthorized, fmt.Errorf("invalid api key"))
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// key 是否为配置 ApiKeys 中的一个，HTTP 接口和 grpcserver 共用
+func (s *Service) ValidApiKey(key string) bool {
+	for _, v := range s.config.ApiKeys {
+		if subtle.ConstantTimeCompare([]byte(v), []byte(key)) == 1 {
+			return true
+		}
+	}
+	return false
+}
+
+func (s *Service) handleSubmissions(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		writeError(w, http.StatusMethodNotAllowed, fmt.Errorf("method %s not allowed", r.Method))
+		return
+	}
+	request := new(SubmissionRequest)
+	err := json.NewDecoder(http.MaxBytesReader(w, r.Body, MaxRequestBodySize)).Decode(request)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+	if err = ValidateHashes(request.Hashes); err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+	if request.Account == "" {
+		request.Account = DefaultAccountKey
+	}
+	if _, err = s.Accounts.Uid(request.Account); err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+	added, err := s.SubmitForAccount(request.Account, request.Hashes)
+	var conflict *AccountConflictError
+	if errors.As(err, &conflict) { //其余 hash 已提交
+		writeJSON(w, http.StatusConflict, map[string]interface{}{
+			"error":     err.Error(),
+			"conflicts": conflict.Hashes,
+			"accepted":  added,
+		})
+		return
+	}
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+	writeJSON(w, http.StatusAccepted, map[string]int{"accepted": added})
+}
+
+func (s *Service) handleBlock(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeError(w, http.StatusMethodNotAllowed, fmt.Errorf("method %s not allowed", r.Method))
+		return
+	}
+	hash := strings.TrimPrefix(r.URL.Path, "/blocks/")
+	if strings.HasSuffix(hash, "/qr") {
+		s.handleBlockQR(w, r, strings.TrimSuffix(hash, "/qr"))
+		return
+	}
+	if hash == "" || strings.Contains(hash, "/") {
+		writeError(w, http.StatusNotFound, fmt.Errorf("not found"))
+		return
+	}
+	b := new(Block)
+	has, err := s.dbEngine.Where("hash=?", hash).Get(b)
+	if err != nil {
+		log.Error("%+v", err.Error())
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+	if !has {
+		writeError(w, http.StatusNotFound, fmt.Errorf("%s not found", hash))
+		return
+	}
+	b.ExplorUrl = BlockChainExploreLink(b.TxId, s.config)
+	writeJSON(w, http.StatusOK, b)
+}
+
+func (s *Service) handleBlockQR(w http.ResponseWriter, r *http.Request, hash string) {
+	if hash == "" || strings.Contains(hash, "/") {
+		writeError(w, http.StatusNotFound, fmt.Errorf("not found"))
+		return
+	}
+	query := r.URL.Query()
+	options := QROptions{Format: query.Get("format"), Level: query.Get("level"), Target: query.Get("target")}
+	if size := query.Get("size"); size != "" {
+		var err error
+		options.Size, err = strconv.Atoi(size)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, fmt.Errorf("invalid size: %s", size))
+			return
+		}
+	}
+	if err := options.setDefaults(); err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+	data, err := s.BlockQR(hash, options)
+	if errors.Is(err, ErrBlockNotFound) {
+		writeError(w, http.StatusNotFound, err)
+		return
+	}
+	if err != nil {
+		writeError(w, http.StatusUnprocessableEntity, err)
+		return
+	}
+	if options.Format == QRFormatSVG {
+		w.Header().Set("Content-Type", "image/svg+xml")
+	} else {
+		w.Header().Set("Content-Type", "image/png")
+	}
+	w.Write(data)
+}
+
+// /commands/{id} 和 /commands/{id}/retry
+func (s *Service) handleCommand(w http.ResponseWriter, r *http.Request) {
+	parts := strings.Split(strings.TrimPrefix(r.URL.Path, "/commands/"), "/")
+	id, err := strconv.ParseInt(parts[0], 10, 64)
+	if err != nil || len(parts) > 2 || (len(parts) == 2 && parts[1] != "retry") {
+		writeError(w, http.StatusNotFound, fmt.Errorf("not found"))
+		return
+	}
+	if len(parts) == 2 {
+		if r.Method != http.MethodPost {
+			writeError(w, http.StatusMethodNotAllowed, fmt.Errorf("method %s not allowed", r.Method))
+			return
+		}
+		err = s.RetryCommand(id)
+		switch err {
+		case nil:
+			writeJSON(w, http.StatusAccepted, map[string]int64{"id": id})
+		case ErrCommandNotFound:
+			writeError(w, http.StatusNotFound, err)
+		case ErrCommandSucceeded, ErrCommandRunning:
+			writeError(w, http.StatusConflict, err)
+		default:
+			writeError(w, http.StatusInternalServerError, err)
+		}
+		return
+	}
+
+	if r.Method != http.MethodGet {
+		writeError(w, http.StatusMethodNotAllowed, fmt.Errorf("method %s not allowed", r.Method))
+		return
+	}
+	session := s.dbEngine.NewSession()
+	defer session.Close()
+	cm := new(CommandModel)
+	has, err := session.ID(id).Get(cm)
+	if err != nil {
+		log.Error("%+v", err.Error())
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+	if !has {
+		writeError(w, http.StatusNotFound, ErrCommandNotFound)
+		return
+	}
+	detail := &CommandDetail{CommandModel: cm}
+	detail.Blocks, err = cm.GetBlock(session)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, detail)
+}
+
+func (s *Service) handleEvents(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeError(w, http.StatusMethodNotAllowed, fmt.Errorf("method %s not allowed", r.Method))
+		return
+	}
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		writeError(w, http.StatusInternalServerError, fmt.Errorf("streaming unsupported"))
+		return
+	}
+	query := r.URL.Query()
+	filter := EventFilter{Account: query.Get("account"), Hashes: query["hash"]}
+	if types := query.Get("type"); types != "" {
+		for _, v := range strings.Split(types, ",") {
+			filter.Types = append(filter.Types, EventType(strings.TrimSpace(v)))
+		}
+	}
+	events, cancel := s.Subscribe(filter)
+	defer cancel()
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	w.WriteHeader(http.StatusOK)
+	flusher.Flush()
+
+	keepAlive := time.NewTicker(SSEKeepAlive)
+	defer keepAlive.Stop()
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case <-keepAlive.C:
+			fmt.Fprint(w, ": keep-alive\n\n")
+		case event := <-events:
+			data, err := json.Marshal(event)
+			if err != nil {
+				log.Error("%+v", err.Error())
+				continue
+			}
+			fmt.Fprintf(w, "event: %s\ndata: %s\n\n", event.Type, data)
+		}
+		flusher.Flush()
+	}
+}
+
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	w.WriteHeader(status)
+	err := json.NewEncoder(w).Encode(v)
+	if err != nil {
+		log.Error("%+v", err.Error())
+	}
+}
+
+func writeError(w http.ResponseWriter, status int, err error) {
+	writeJSON(w, status, map[string]string{"error": err.Error()})
+}
@@ -0,0 +1,141 @@
+package vechain
+
+import (
+	"bufio"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestHTTPHandler_Validation(t *testing.T) {
+	accounts := NewAccountRegistry()
+	accounts.Register(DefaultAccountKey, "U0")
+	s := &Service{config: &VechainConfig{ApiKeys: []string{"secret"}}, Accounts: accounts}
+	handler := s.HTTPHandler()
+
+	cases := []struct {
+		name   string
+		method string
+		path   string
+		key    string
+		body   string
+		status int
+	}{
+		{"no key", "GET", "/blocks/0x01", "", "", http.StatusUnauthorized},
+		{"wrong key", "GET", "/blocks/0x01", "other", "", http.StatusUnauthorized},
+		{"method", "GET", "/submissions", "secret", "", http.StatusMethodNotAllowed},
+		{"bad body", "POST", "/submissions", "secret", "{", http.StatusBadRequest},
+		{"no hashes", "POST", "/submissions", "secret", `{"hashes":[]}`, http.StatusBadRequest},
+		{"blank hash", "POST", "/submissions", "secret", `{"hashes":["0x01"," "]}`, http.StatusBadRequest},
+		{"unknown account", "POST", "/submissions", "secret", `{"account":"brand-b","hashes":["0x01"]}`, http.StatusBadRequest},
+		{"empty hash", "GET", "/blocks/", "secret", "", http.StatusNotFound},
+		{"qr size", "GET", "/blocks/0x01/qr?size=abc", "secret", "", http.StatusBadRequest},
+		{"qr format", "GET", "/blocks/0x01/qr?format=gif", "secret", "", http.StatusBadRequest},
+		{"bad command id", "GET", "/commands/abc", "secret", "", http.StatusNotFound},
+		{"bad command action", "POST", "/commands/1/cancel", "secret", "", http.StatusNotFound},
+		{"retry method", "GET", "/commands/1/retry", "secret", "", http.StatusMethodNotAllowed},
+	}
+	for _, c := range cases {
+		r := httptest.NewRequest(c.method, c.path, strings.NewReader(c.body))
+		if c.key != "" {
+			r.Header.Set("X-Api-Key", c.key)
+		}
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, r)
+		if w.Code != c.status {
+			t.Errorf("%s: expect %d, got %d %s", c.name, c.status, w.Code, w.Body.String())
+		}
+	}
+
+	r := httptest.NewRequest("GET", "/submissions", nil)
+	r.Header.Set("Authorization", "Bearer secret")
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, r)
+	if w.Code != http.StatusMethodNotAllowed {
+		t.Errorf("bearer: expect %d, got %d", http.StatusMethodNotAllowed, w.Code)
+	}
+}
+
+func TestHTTPHandler_Submissions(t *testing.T) {
+	s, cleanup := newTestService(t, &VechainConfig{ApiKeys: []string{"secret"}})
+	defer cleanup()
+	s.Accounts.Register("brand-b", "U1")
+	insertTestBlocks(t, s, &Block{Hash: "H1", Vid: "V1", Account: "brand-b", State: BlockStateToOccupy})
+	handler := s.HTTPHandler()
+
+	cases := []struct {
+		body   string
+		status int
+		expect string
+	}{
+		{`{"hashes":["H2"]}`, http.StatusAccepted, `{"accepted":1}`},
+		{`{"hashes":["H2"]}`, http.StatusAccepted, `{"accepted":0}`}, //已提交过
+		{`{"hashes":["H1","H3"]}`, http.StatusConflict, `"accepted":1,"conflicts":["H1"]`},
+		{`{"hashes":[" "]}`, http.StatusBadRequest, `hashes[0] is blank`},
+		{`{"hashes":["` + strings.Repeat("a", HashMaxLength+1) + `"]}`, http.StatusBadRequest, `hashes[0] is longer than`},
+	}
+	for _, c := range cases {
+		r := httptest.NewRequest("POST", "/submissions", strings.NewReader(c.body))
+		r.Header.Set("X-Api-Key", "secret")
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, r)
+		if w.Code != c.status || !strings.Contains(w.Body.String(), c.expect) {
+			t.Errorf("%s: expect %d %s, got %d %s", c.body, c.status, c.expect, w.Code, w.Body.String())
+		}
+	}
+	if n, _ := s.dbEngine.In("hash", "H2", "H3").Count(&Block{}); n != 2 {
+		t.Errorf("expect H2 and H3 submitted, got %d", n)
+	}
+}
+
+func TestHTTPHandler_Events(t *testing.T) {
+	s := &Service{config: &VechainConfig{ApiKeys: []string{"secret"}, ExploreLink: "https://explore/%s"}}
+	server := httptest.NewServer(s.HTTPHandler())
+	defer server.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	r, _ := http.NewRequest("GET", server.URL+"/events?type=succeeded", nil)
+	r = r.WithContext(ctx)
+	r.Header.Set("X-Api-Key", "secret")
+	resp, err := http.DefaultClient.Do(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	if resp.Header.Get("Content-Type") != "text/event-stream" {
+		t.Fatalf("unexpected content type: %s", resp.Header.Get("Content-Type"))
+	}
+
+	go func() {
+		for { //等待订阅建立
+			s.events.mutex.RLock()
+			n := len(s.events.subscriptions)
+			s.events.mutex.RUnlock()
+			if n > 0 {
+				break
+			}
+			time.Sleep(10 * time.Millisecond)
+		}
+		s.publishBlocks(EventPosted, 1, []*Block{{Hash: "H0"}})
+		s.publishBlocks(EventSucceeded, 1, []*Block{{Hash: "H1", TxId: "0x01"}})
+	}()
+
+	reader := bufio.NewReader(resp.Body)
+	var lines []string
+	for len(lines) < 2 {
+		line, err := reader.ReadString('\n')
+		if err != nil {
+			t.Fatal(err)
+		}
+		if line = strings.TrimSpace(line); line != "" {
+			lines = append(lines, line)
+		}
+	}
+	if lines[0] != "event: succeeded" || !strings.Contains(lines[1], `"hash":"H1"`) || !strings.Contains(lines[1], `"explor_url":"https://explore/0x01"`) {
+		t.Errorf("unexpected event: %v", lines)
+	}
+}
@@ -0,0 +1,333 @@
+package vechain
+
+import (
+	"bufio"
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/myafeier/log"
+)
+
+// ============批量导入============
+// 逐行读取 CSV/JSONL 文件，分块提交，每块提交后记录断点，中断后重新执行从断点继续
+
+const (
+	ImportFormatCSV   = "csv"
+	ImportFormatJSONL = "jsonl"
+
+	ImportChunkSize          = 1000 //每次提交的 hash 数
+	ImportMaxRunningCommands = 50   //运行中的命令超过此数时暂停提交，避免同时发起过多接口请求
+	ImportMaxRejections      = 1000 //报告中保留的拒绝明细条数
+	importCheckpointSuffix   = ".checkpoint"
+)
+
+// ImportOptions 导入选项，各字段为空时取默认值
+type ImportOptions struct {
+	Format             string //csv 或 jsonl，默认按文件扩展名
+	Column             string //CSV 列名或 JSONL 字段名，默认 hash；CSV 表头中没有该列时视为无表头，取第一列
+	Account            string //子账户标识，默认为默认账户
+	ChunkSize          int
+	MaxRunningCommands int
+	CheckpointFile     string //默认为 文件路径.checkpoint，导入完成后删除
+}
+
+// ImportRejection 被拒绝的行
+type ImportRejection struct {
+	Row    int    `json:"row"` //数据行号，从 1 开始，不含表头
+	Value  string `json:"value"`
+	Reason string `json:"reason"`
+}
+
+// ImportReport 导入报告
+type ImportReport struct {
+	Path       string             `json:"path"`
+	Rows       int                `json:"rows"`     //已处理的数据行
+	New        int                `json:"new"`      //新提交的 hash
+	Existing   int                `json:"existing"` //已存在（含文件内重复）的 hash
+	Rejected   int                `json:"rejected"` //无效的行
+	Resumed    int                `json:"resumed"`  //从断点恢复时跳过的行
+	Rejections []*ImportRejection `json:"rejections,omitempty"`
+	Done       bool               `json:"done"`
+}
+
+func (self *ImportReport) reject(row int, value, reason string) {
+	self.Rejected++
+	if len(self.Rejections) < ImportMaxRejections {
+		self.Rejections = append(self.Rejections, &ImportRejection{Row: row, Value: value, Reason: reason})
+	}
+}
+
+func (self *ImportOptions) setDefaults(path string) {
+	if self.Format == "" {
+		self.Format = strings.TrimPrefix(strings.ToLower(filepath.Ext(path)), ".")
+		if self.Format == "json" || self.Format == "ndjson" {
+			self.Format = ImportFormatJSONL
+		}
+	}
+	if self.Column == "" {
+		self.Column = "hash"
+	}
+	if self.Account == "" {
+		self.Account = DefaultAccountKey
+	}
+	if self.ChunkSize <= 0 {
+		self.ChunkSize = ImportChunkSize
+	}
+	if self.MaxRunningCommands <= 0 {
+		self.MaxRunningCommands = ImportMaxRunningCommands
+	}
+	if self.CheckpointFile == "" {
+		self.CheckpointFile = path + importCheckpointSuffix
+	}
+}
+
+// 从文件批量导入 hash，返回导入报告
+//  断点文件存在时跳过已处理的行并累加之前的统计；出错时已提交的部分保留在断点中
+func (s *Service) Import(ctx context.Context, path string, options ImportOptions) (report *ImportReport, err error) {
+	options.setDefaults(path)
+	if options.Format != ImportFormatCSV && options.Format != ImportFormatJSONL {
+		err = fmt.Errorf("unsupported import format: %s", options.Format)
+		return
+	}
+	if _, err = s.Accounts.Uid(options.Account); err != nil {
+		return
+	}
+	report, err = readImportCheckpoint(options.CheckpointFile)
+	if err != nil {
+		return
+	}
+	report.Path = path
+	report.Resumed = report.Rows
+
+	f, err := os.Open(path)
+	if err != nil {
+		return
+	}
+	defer f.Close()
+
+	var chunk []string
+	var values = make(map[string]int) //本块内 hash => 行号
+	submit := func(row int) (err error) {
+		if len(chunk) > 0 {
+			err = s.importChunk(ctx, options, chunk, values, report)
+			if err != nil {
+				return
+			}
+		}
+		report.Rows = row
+		chunk = chunk[:0]
+		values = make(map[string]int)
+		return writeImportCheckpoint(options.CheckpointFile, report)
+	}
+
+	lastRow := 0
+	err = readImportRows(f, options.Format, options.Column, func(row int, value string, rowErr error) error {
+		lastRow = row
+		if row <= report.Resumed {
+			return nil
+		}
+		if rowErr != nil {
+			report.reject(row, value, rowErr.Error())
+		} else if value = strings.TrimSpace(value); value == "" {
+			report.reject(row, value, "empty hash")
+		} else if len(value) > HashMaxLength {
+			report.reject(row, value, fmt.Sprintf("hash longer than %d", HashMaxLength))
+		} else if _, ok := values[value]; ok {
+			report.Existing++
+		} else {
+			values[value] = row
+			chunk = append(chunk, value)
+		}
+		if len(chunk) >= options.ChunkSize {
+			return submit(row)
+		}
+		return ctx.Err()
+	})
+	if err != nil {
+		return
+	}
+	err = submit(lastRow)
+	if err != nil {
+		return
+	}
+	report.Done = true
+	os.Remove(options.CheckpointFile)
+	log.Info("import %s done, rows:%d new:%d existing:%d rejected:%d", path, report.Rows, report.New, report.Existing, report.Rejected)
+	return
+}
+
+//提交一块，已存在的 hash 不再提交，已由其他账户提交的 hash 按行拒绝；rows 为 hash => 行号
+func (s *Service) importChunk(ctx context.Context, options ImportOptions, chunk []string, rows map[string]int, report *ImportReport) (err error) {
+	for s.RunningCommands() >= options.MaxRunningCommands {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(time.Second):
+		}
+	}
+
+	var exists []*Block
+	err = s.dbEngine.In("hash", chunk).Cols("hash", "account").Find(&exists)
+	if err != nil {
+		log.Error("%+v", err.Error())
+		return
+	}
+	existSet := make(map[string]bool, len(exists))
+	for _, v := range exists {
+		existSet[v.Hash] = true
+		if accountKey(v.Account) != options.Account {
+			report.reject(rows[v.Hash], v.Hash, ErrHashOwnedByOtherAccount.Error())
+		} else {
+			report.Existing++
+		}
+	}
+	var hashes []string
+	for _, v := range chunk {
+		if !existSet[v] {
+			hashes = append(hashes, v)
+		}
+	}
+	if len(hashes) == 0 {
+		return
+	}
+	err = s.AsyncSubmitForAccount(options.Account, hashes)
+	var conflict *AccountConflictError
+	if errors.As(err, &conflict) { //检查之后被其他账户提交
+		for _, v := range conflict.Hashes {
+			report.reject(rows[v], v, ErrHashOwnedByOtherAccount.Error())
+		}
+		report.New -= len(conflict.Hashes)
+		err = nil
+	}
+	if err != nil {
+		return
+	}
+	report.New += len(hashes)
+	return
+}
+
+// 逐行读取，fn 返回错误时停止；单行格式错误通过 rowErr 传给 fn
+func readImportRows(r io.Reader, format, column string, fn func(row int, value string, rowErr error) error) (err error) {
+	switch format {
+	case ImportFormatCSV:
+		return readImportCSV(r, column, fn)
+	case ImportFormatJSONL:
+		return readImportJSONL(r, column, fn)
+	}
+	return fmt.Errorf("unsupported import format: %s", format)
+}
+
+func readImportCSV(r io.Reader, column string, fn func(row int, value string, rowErr error) error) (err error) {
+	reader := csv.NewReader(r)
+	reader.FieldsPerRecord = -1
+	reader.ReuseRecord = true
+	index := -1
+	row := 0
+	for {
+		var record []string
+		record, err = reader.Read()
+		if err == io.EOF {
+			return nil
+		}
+		if _, ok := err.(*csv.ParseError); ok {
+			row++
+			if err = fn(row, "", err); err != nil {
+				return
+			}
+			continue
+		}
+		if err != nil {
+			return
+		}
+		if index < 0 { //首行：有 column 列时作为表头，否则取第一列
+			index = 0
+			isHeader := false
+			for k, v := range record {
+				if strings.EqualFold(strings.TrimSpace(strings.TrimPrefix(v, "\ufeff")), column) {
+					index = k
+					isHeader = true
+					break
+				}
+			}
+			if isHeader {
+				continue
+			}
+		}
+		row++
+		if index >= len(record) {
+			err = fn(row, "", fmt.Errorf("missing column %s", column))
+		} else {
+			err = fn(row, record[index], nil)
+		}
+		if err != nil {
+			return
+		}
+	}
+}
+
+func readImportJSONL(r io.Reader, column string, fn func(row int, value string, rowErr error) error) (err error) {
+	reader := bufio.NewReader(r)
+	row := 0
+	for {
+		var line string
+		line, err = reader.ReadString('\n')
+		if err != nil && err != io.EOF {
+			return
+		}
+		eof := err == io.EOF
+		err = nil
+		if line = strings.TrimSpace(line); line != "" {
+			row++
+			var item map[string]interface{}
+			if jsonErr := json.Unmarshal([]byte(line), &item); jsonErr != nil {
+				err = fn(row, line, jsonErr)
+			} else if value, ok := item[column].(string); !ok {
+				err = fn(row, line, fmt.Errorf("missing string field %s", column))
+			} else {
+				err = fn(row, value, nil)
+			}
+			if err != nil {
+				return
+			}
+		}
+		if eof {
+			return
+		}
+	}
+}
+
+func readImportCheckpoint(path string) (report *ImportReport, err error) {
+	report = new(ImportReport)
+	data, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) {
+		err = nil
+		return
+	}
+	if err != nil {
+		return
+	}
+	err = json.Unmarshal(data, report)
+	if err != nil {
+		err = fmt.Errorf("%s: %s", path, err.Error())
+		return
+	}
+	log.Info("resume import from row %d", report.Rows)
+	return
+}
+
+func writeImportCheckpoint(path string, report *ImportReport) (err error) {
+	data, err := json.Marshal(report)
+	if err != nil {
+		return
+	}
+	return writeFileAtomic(path, data)
+}
@@ -0,0 +1,116 @@
+package vechain
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+type importRow struct {
+	Row   int
+	Value string
+	Err   bool
+}
+
+func readAllImportRows(t *testing.T, data, format, column string) (rows []importRow) {
+	err := readImportRows(strings.NewReader(data), format, column, func(row int, value string, rowErr error) error {
+		rows = append(rows, importRow{row, value, rowErr != nil})
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return
+}
+
+func TestReadImportRows(t *testing.T) {
+	cases := []struct {
+		name   string
+		format string
+		column string
+		data   string
+		expect []importRow
+	}{
+		{"csv header", ImportFormatCSV, "hash", "\ufeffsku,Hash\nA,0x01\nB\nC,0x02\n", []importRow{{1, "0x01", false}, {2, "", true}, {3, "0x02", false}}},
+		{"csv no header", ImportFormatCSV, "hash", "0x01\n0x02", []importRow{{1, "0x01", false}, {2, "0x02", false}}},
+		{"csv bad quote", ImportFormatCSV, "hash", "hash\n\"0x01\n", []importRow{{1, "", true}}},
+		{"jsonl", ImportFormatJSONL, "hash", "{\"hash\":\"0x01\"}\n\nnot json\n{\"sku\":\"A\"}\n{\"hash\":\"0x02\"}", []importRow{{1, "0x01", false}, {2, "not json", true}, {3, `{"sku":"A"}`, true}, {4, "0x02", false}}},
+		{"jsonl column", ImportFormatJSONL, "digest", "{\"digest\":\"0x01\"}\n", []importRow{{1, "0x01", false}}},
+	}
+	for _, c := range cases {
+		rows := readAllImportRows(t, c.data, c.format, c.column)
+		if !reflect.DeepEqual(rows, c.expect) {
+			t.Errorf("%s: expect %v, got %v", c.name, c.expect, rows)
+		}
+	}
+}
+
+func TestImportCheckpoint(t *testing.T) {
+	dir, err := ioutil.TempDir("", "import")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "hashes.csv.checkpoint")
+
+	report, err := readImportCheckpoint(path)
+	if err != nil || report.Rows != 0 {
+		t.Fatalf("expect empty report without checkpoint, got %+v %v", report, err)
+	}
+	report = &ImportReport{Rows: 2000, New: 1900, Existing: 90}
+	report.reject(7, "", "empty hash")
+	err = writeImportCheckpoint(path, report)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resumed, err := readImportCheckpoint(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(resumed, report) {
+		t.Errorf("expect %+v, got %+v", report, resumed)
+	}
+}
+
+func TestImportOptions_Defaults(t *testing.T) {
+	options := ImportOptions{}
+	options.setDefaults("/data/hashes.NDJSON")
+	if options.Format != ImportFormatJSONL || options.Column != "hash" || options.Account != DefaultAccountKey ||
+		options.ChunkSize != ImportChunkSize || options.CheckpointFile != "/data/hashes.NDJSON.checkpoint" {
+		t.Errorf("unexpected defaults: %+v", options)
+	}
+}
+
+func TestImport_AccountConflict(t *testing.T) {
+	s, cleanup := newTestService(t, &VechainConfig{})
+	defer cleanup()
+	s.Accounts.Register("brand-b", "U1")
+	insertTestBlocks(t, s,
+		&Block{Hash: "H1", Vid: "V1", Account: "brand-b", State: BlockStateToOccupy},
+		&Block{Hash: "H2", Vid: "V2", State: BlockStateToOccupy},
+	)
+
+	dir, err := ioutil.TempDir("", "vechain-import")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "hashes.csv")
+	if err = ioutil.WriteFile(path, []byte("hash\nH1\nH2\nH3\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	report, err := s.Import(context.Background(), path, ImportOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if report.New != 1 || report.Existing != 1 || report.Rejected != 1 {
+		t.Errorf("unexpected report %+v", *report)
+	}
+	if len(report.Rejections) != 1 || report.Rejections[0].Row != 1 || report.Rejections[0].Value != "H1" {
+		t.Errorf("expect row 1 rejected, got %+v", report.Rejections)
+	}
+}
@@ -5,3 +5,8 @@ type IObserver interface {
 	//上链数据的处理
 	Execute(hash, vid, txid string) error
 }
+
+//滞留区块对账报告观察者
+type IReconcileObserver interface {
+	Report(report *StuckReport)
+}
@@ -0,0 +1,228 @@
+package vechain
+
+import (
+	"archive/zip"
+	"context"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/myafeier/log"
+	"github.com/skip2/go-qrcode"
+)
+
+// ============二维码============
+// 为区块的扫码 url、vid 或浏览器地址生成二维码，批量模式输出 zip 供标签打印
+
+const (
+	QRFormatPNG = "png"
+	QRFormatSVG = "svg"
+
+	QRTargetScan    = "scan"    //按 ScanUrlFormat 生成的扫码地址，未配置时使用 vid
+	QRTargetVid     = "vid"     //vid
+	QRTargetExplore = "explore" //区块链浏览器中的交易
+
+	QRDefaultSize = 256 //默认边长，PNG 为像素，SVG 为 viewBox 尺寸
+	QRMaxSize     = 4096
+)
+
+// QROptions 二维码选项，各字段为空时取默认值
+type QROptions struct {
+	Format string //png（默认）或 svg
+	Size   int
+	Level  string //纠错级别 L、M（默认）、Q、H
+	Target string //scan（默认）、vid、explore
+}
+
+func (self *QROptions) setDefaults() (err error) {
+	if self.Format == "" {
+		self.Format = QRFormatPNG
+	}
+	if self.Size == 0 {
+		self.Size = QRDefaultSize
+	}
+	if self.Level == "" {
+		self.Level = "M"
+	}
+	if self.Target == "" {
+		self.Target = QRTargetScan
+	}
+	self.Format = strings.ToLower(self.Format)
+	self.Level = strings.ToUpper(self.Level)
+	if self.Format != QRFormatPNG && self.Format != QRFormatSVG {
+		return fmt.Errorf("unsupported qr format: %s", self.Format)
+	}
+	if self.Size < 21 || self.Size > QRMaxSize {
+		return fmt.Errorf("qr size must be between 21 and %d", QRMaxSize)
+	}
+	if _, ok := qrLevels[self.Level]; !ok {
+		return fmt.Errorf("invalid qr level: %s", self.Level)
+	}
+	if self.Target != QRTargetScan && self.Target != QRTargetVid && self.Target != QRTargetExplore {
+		return fmt.Errorf("invalid qr target: %s", self.Target)
+	}
+	return
+}
+
+var qrLevels = map[string]qrcode.RecoveryLevel{
+	"L": qrcode.Low,
+	"M": qrcode.Medium,
+	"Q": qrcode.High,
+	"H": qrcode.Highest,
+}
+
+// vid 的扫码地址，未配置 ScanUrlFormat 时为 vid 本身
+//  Block.ScanUrl 是抢占接口按请求返回的 url，同一请求的区块相同，不用于区分单个 vid
+func ScanUrl(vid string, config *VechainConfig) string {
+	if vid == "" || config == nil || config.ScanUrlFormat == "" {
+		return vid
+	}
+	return fmt.Sprintf(config.ScanUrlFormat, vid)
+}
+
+// 二维码内容
+func QRContent(b *Block, config *VechainConfig, target string) (content string, err error) {
+	switch target {
+	case QRTargetScan, "":
+		content = ScanUrl(b.Vid, config)
+	case QRTargetVid:
+		content = b.Vid
+	case QRTargetExplore:
+		if b.TxId != "" {
+			content = BlockChainExploreLink(b.TxId, config)
+		}
+	default:
+		err = fmt.Errorf("invalid qr target: %s", target)
+		return
+	}
+	if content == "" {
+		err = fmt.Errorf("%s has no %s for qr code yet", b.Hash, target)
+	}
+	return
+}
+
+// 生成二维码图片
+func RenderQR(content string, options QROptions) (data []byte, err error) {
+	err = options.setDefaults()
+	if err != nil {
+		return
+	}
+	q, err := qrcode.New(content, qrLevels[options.Level])
+	if err != nil {
+		log.Error("%+v", err.Error())
+		return
+	}
+	if options.Format == QRFormatSVG {
+		return qrSVG(q.Bitmap(), options.Size), nil
+	}
+	return q.PNG(options.Size)
+}
+
+//每个深色模块一个 rect，viewBox 按模块数，宽高为 size
+func qrSVG(bitmap [][]bool, size int) []byte {
+	n := len(bitmap)
+	var b strings.Builder
+	fmt.Fprintf(&b, `<svg xmlns="http://www.w3.org/2000/svg" width="%d" height="%d" viewBox="0 0 %d %d" shape-rendering="crispEdges">`, size, size, n, n)
+	fmt.Fprintf(&b, `<rect width="%d" height="%d" fill="#fff"/>`, n, n)
+	for y, row := range bitmap {
+		for x, dark := range row {
+			if dark {
+				fmt.Fprintf(&b, `<rect x="%d" y="%d" width="1" height="1"/>`, x, y)
+			}
+		}
+	}
+	b.WriteString("</svg>\n")
+	return []byte(b.String())
+}
+
+// 生成 hash 对应区块的二维码
+func (s *Service) BlockQR(hash string, options QROptions) (data []byte, err error) {
+	b := new(Block)
+	has, err := s.dbEngine.Where("hash=?", hash).Get(b)
+	if err != nil {
+		log.Error("%+v", err.Error())
+		return
+	}
+	if !has {
+		err = fmt.Errorf("%w: %s", ErrBlockNotFound, hash)
+		return
+	}
+	content, err := QRContent(b, s.config, options.Target)
+	if err != nil {
+		return
+	}
+	return RenderQR(content, options)
+}
+
+// 批量生成二维码，以 zip 写入 w，文件名为 hash.png 或 hash.svg
+//  重复的 hash 只生成一次，不存在或还没有对应内容的 hash 跳过，返回跳过的 hash
+func (s *Service) BatchQR(ctx context.Context, w io.Writer, hashes []string, options QROptions) (skipped []string, err error) {
+	err = options.setDefaults()
+	if err != nil {
+		return
+	}
+	//zip 中不能有同名文件
+	seen := make(map[string]bool, len(hashes))
+	unique := make([]string, 0, len(hashes))
+	for _, v := range hashes {
+		if !seen[v] {
+			seen[v] = true
+			unique = append(unique, v)
+		}
+	}
+	hashes = unique
+	archive := zip.NewWriter(w)
+	for start := 0; start < len(hashes); start += ExportBatchSize {
+		if err = ctx.Err(); err != nil {
+			return
+		}
+		end := start + ExportBatchSize
+		if end > len(hashes) {
+			end = len(hashes)
+		}
+		var blocks []*Block
+		err = s.dbEngine.In("hash", hashes[start:end]).Find(&blocks)
+		if err != nil {
+			log.Error("%+v", err.Error())
+			return
+		}
+		found := make(map[string]*Block, len(blocks))
+		for _, v := range blocks {
+			found[v.Hash] = v
+		}
+		for _, hash := range hashes[start:end] {
+			b, ok := found[hash]
+			if !ok {
+				skipped = append(skipped, hash)
+				continue
+			}
+			var content string
+			content, err = QRContent(b, s.config, options.Target)
+			if err != nil {
+				err = nil
+				skipped = append(skipped, hash)
+				continue
+			}
+			name := strings.NewReplacer("/", "_", "\\", "_").Replace(hash)
+			err = writeQRFile(archive, name+"."+options.Format, content, options)
+			if err != nil {
+				return
+			}
+		}
+	}
+	err = archive.Close()
+	return
+}
+
+func writeQRFile(archive *zip.Writer, name, content string, options QROptions) (err error) {
+	data, err := RenderQR(content, options)
+	if err != nil {
+		return
+	}
+	f, err := archive.Create(name)
+	if err != nil {
+		return
+	}
+	_, err = f.Write(data)
+	return
+}
@@ -0,0 +1,120 @@
+package vechain
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"strings"
+	"testing"
+)
+
+func TestQRContent(t *testing.T) {
+	config := &VechainConfig{ExploreLink: "https://explore/%s"}
+	b := &Block{Hash: "H1", Vid: "0XV1"}
+	cases := []struct {
+		target string
+		expect string
+		err    bool
+	}{
+		{QRTargetScan, "0XV1", false},
+		{QRTargetVid, "0XV1", false},
+		{QRTargetExplore, "", true},
+		{"label", "", true},
+	}
+	for _, c := range cases {
+		content, err := QRContent(b, config, c.target)
+		if content != c.expect || (err != nil) != c.err {
+			t.Errorf("%s: expect %q err:%v, got %q %v", c.target, c.expect, c.err, content, err)
+		}
+	}
+
+	//抢占接口返回的 url 属于整个请求，不作为扫码内容
+	b.ScanUrl = "https://scan/request"
+	b.TxId = "0x01"
+	if content, _ := QRContent(b, config, ""); content != "0XV1" {
+		t.Errorf("expect vid, got %s", content)
+	}
+	config.ScanUrlFormat = "https://scan/%s"
+	if content, _ := QRContent(b, config, ""); content != "https://scan/0XV1" {
+		t.Errorf("expect per-vid scan url, got %s", content)
+	}
+	if content, _ := QRContent(b, config, QRTargetExplore); content != "https://explore/0x01" {
+		t.Errorf("expect explore url, got %s", content)
+	}
+}
+
+func TestRenderQR(t *testing.T) {
+	png, err := RenderQR("https://scan/0XV1", QROptions{})
+	if err != nil || !bytes.HasPrefix(png, []byte("\x89PNG")) {
+		t.Fatalf("expect png, got err:%v", err)
+	}
+	svg, err := RenderQR("https://scan/0XV1", QROptions{Format: "SVG", Size: 128, Level: "h"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(string(svg), "<svg ") || !strings.Contains(string(svg), `width="128"`) {
+		t.Errorf("unexpected svg: %.80s", svg)
+	}
+	for _, options := range []QROptions{{Format: "gif"}, {Size: 10}, {Level: "X"}, {Target: "label"}} {
+		if _, err = RenderQR("x", options); err == nil {
+			t.Errorf("expect error for %+v", options)
+		}
+	}
+}
+
+//同一抢占响应的两个区块，二维码内容不同
+func TestBlockQR_SharedResponseUrl(t *testing.T) {
+	s, cleanup := newTestService(t, &VechainConfig{})
+	defer cleanup()
+	session := s.dbEngine.NewSession()
+	cmd, err := NewOccupyVidCommand(session, context.Background(), nil, "", []*Block{{Hash: "H1"}, {Hash: "H2"}})
+	session.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+	response := &OccupyVidResponse{Status: "SUCCESS", Url: "https://scan/request", SuccessList: []string{cmd.blocks[0].Vid, cmd.blocks[1].Vid}}
+	if _, err = cmd.next(s.dbEngine.NewSession(), nil, "U0", response); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, format := range []string{"", "https://scan/%s"} {
+		s.config.ScanUrlFormat = format
+		var contents []string
+		for _, hash := range []string{"H1", "H2"} {
+			b := new(Block)
+			if _, err = s.dbEngine.Where("hash=?", hash).Get(b); err != nil {
+				t.Fatal(err)
+			}
+			content, err := QRContent(b, s.config, QRTargetScan)
+			if err != nil || !strings.Contains(content, b.Vid) {
+				t.Fatalf("format %q: expect content of %s, got %q %v", format, b.Vid, content, err)
+			}
+			contents = append(contents, content)
+		}
+		if contents[0] == contents[1] {
+			t.Errorf("format %q: blocks of one response share qr content %q", format, contents[0])
+		}
+	}
+}
+
+func TestBatchQR_Duplicates(t *testing.T) {
+	s, cleanup := newTestService(t, &VechainConfig{})
+	defer cleanup()
+	insertTestBlocks(t, s, &Block{Hash: "H1", Vid: "V1", State: BlockStatePosted})
+
+	buf := new(bytes.Buffer)
+	skipped, err := s.BatchQR(context.Background(), buf, []string{"H1", "H9", "H1", "H9"}, QROptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(skipped) != 1 || skipped[0] != "H9" {
+		t.Errorf("expect H9 skipped once, got %v", skipped)
+	}
+	archive, err := zip.NewReader(bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(archive.File) != 1 || archive.File[0].Name != "H1.png" {
+		t.Errorf("expect only H1.png, got %d files", len(archive.File))
+	}
+}
@@ -0,0 +1,380 @@
+package vechain
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/myafeier/log"
+)
+
+// ============对账============
+// 本地 Block 与 ToolChain 平台记录的比对
+
+type ReconcileIssue string
+
+const (
+	ReconcileMissingTxId      ReconcileIssue = "MISSING_TXID"       //平台已上链，本地缺少txid
+	ReconcileTxIdMismatch     ReconcileIssue = "TXID_MISMATCH"      //本地与平台的txid不一致
+	ReconcileDataHashMismatch ReconcileIssue = "DATA_HASH_MISMATCH" //平台记录的数据hash与本地不一致
+	ReconcileUnknownVid       ReconcileIssue = "UNKNOWN_VID"        //本地已上链，平台查询不到该vid
+)
+
+const ReconcileAmountPerPage = 100 //对账时每次读取的区块数量
+
+//单条差异
+type ReconcileItem struct {
+	BlockId int64          `json:"block_id"`
+	Hash    string         `json:"hash"`
+	Vid     string         `json:"vid"`
+	Issue   ReconcileIssue `json:"issue"`
+	Local   string         `json:"local"`  //本地值
+	Remote  string         `json:"remote"` //平台值
+	Fixed   bool           `json:"fixed"`  //是否已修复
+}
+
+//对账报告
+type ReconcileReport struct {
+	Started  time.Time        `json:"started"`
+	Finished time.Time        `json:"finished"`
+	Checked  int              `json:"checked"` //检查的区块数量
+	Fixed    int              `json:"fixed"`   //修复的区块数量
+	Errors   []string         `json:"errors"`  //查询或修复失败
+	Items    []*ReconcileItem `json:"items"`
+}
+
+func (self *ReconcileReport) String() string {
+	return fmt.Sprintf("checked:%d mismatched:%d fixed:%d errors:%d", self.Checked, len(self.Items), self.Fixed, len(self.Errors))
+}
+
+// 逐个比对已上链命令的本地区块与平台记录
+//  平台按 requestNo 识别同一请求，对每个成功的上链命令重发原报文，用返回的 txList 比对该命令的区块
+//  fix 为 true 时用平台记录修复缺失或不一致的txid，数据hash不一致和未知vid只报告
+func (s *Service) Reconcile(ctx context.Context, fix bool) (report *ReconcileReport, err error) {
+	report = &ReconcileReport{Started: time.Now()}
+	defer func() {
+		report.Finished = time.Now()
+		log.Info("reconcile finished: %s", report.String())
+	}()
+
+	session := s.dbEngine.NewSession()
+	defer session.Close()
+
+	var lastId int64
+	for {
+		if err = ctx.Err(); err != nil {
+			return
+		}
+		var cms []*CommandModel
+		err = session.Where("id>?", lastId).And("cmd=?", Command_Post_Artifact).And("state=?", CommandStateOfSuccess).Asc("id").Limit(ReconcileAmountPerPage).Find(&cms)
+		if err != nil {
+			log.Error("%+v", err.Error())
+			return
+		}
+		if len(cms) == 0 {
+			return
+		}
+		for _, v := range cms {
+			lastId = v.Id
+			cmd, cmdErr := GetCommandById(session, ctx, v.Id)
+			if cmdErr == nil {
+				cmdErr = s.reconcileCommand(ctx, cmd.(*PostArtifactCommand), fix, report)
+			}
+			if cmdErr != nil {
+				if err = ctx.Err(); err != nil {
+					return
+				}
+				report.Errors = append(report.Errors, fmt.Sprintf("command %d: %s", v.Id, cmdErr.Error()))
+			}
+		}
+	}
+}
+
+//重发上链命令的原报文，比对返回的 txList 与命令的区块
+func (s *Service) reconcileCommand(ctx context.Context, c *PostArtifactCommand, fix bool, report *ReconcileReport) (err error) {
+	request, err := c.loadRequest(s)
+	if err != nil {
+		return
+	}
+	response, err := PostArtifactOnce(ctx, s.config, s.Token, request)
+	if err != nil {
+		return
+	}
+	if response.Status != string(CommandStateOfSuccess) {
+		err = fmt.Errorf("remote status %s", response.Status)
+		return
+	}
+	remote := make(map[string]*PostArtifactResponseData)
+	for _, v := range response.TxList {
+		remote[v.Vid] = v
+	}
+	for _, v := range c.blocks {
+		if v.Vid == "" {
+			continue
+		}
+		report.Checked++
+		item, fixErr := s.reconcileBlock(v, remote[v.Vid], fix)
+		if fixErr != nil {
+			report.Errors = append(report.Errors, fmt.Sprintf("%s: %s", v.Vid, fixErr.Error()))
+			continue
+		}
+		if item != nil {
+			report.Items = append(report.Items, item)
+			if item.Fixed {
+				report.Fixed++
+			}
+		}
+	}
+	return
+}
+
+//remote 为 nil 表示平台返回的 txList 中没有该 vid
+func (s *Service) reconcileBlock(b *Block, remote *PostArtifactResponseData, fix bool) (item *ReconcileItem, err error) {
+	if remote == nil {
+		if b.TxId != "" {
+			item = &ReconcileItem{BlockId: b.Id, Hash: b.Hash, Vid: b.Vid, Issue: ReconcileUnknownVid, Local: b.TxId}
+		}
+		return
+	}
+
+	if remote.DataHash != "" && remote.DataHash != b.Hash {
+		item = &ReconcileItem{BlockId: b.Id, Hash: b.Hash, Vid: b.Vid, Issue: ReconcileDataHashMismatch, Local: b.Hash, Remote: remote.DataHash}
+		return
+	}
+	if remote.TxId == "" || remote.TxId == b.TxId {
+		return
+	}
+
+	item = &ReconcileItem{BlockId: b.Id, Hash: b.Hash, Vid: b.Vid, Issue: ReconcileTxIdMismatch, Local: b.TxId, Remote: remote.TxId}
+	if b.TxId == "" {
+		item.Issue = ReconcileMissingTxId
+	}
+	if fix {
+		err = s.applyRemoteTx(b, remote)
+		if err != nil {
+			return
+		}
+		item.Fixed = true
+	}
+	return
+}
+
+// 用平台的上链记录更新本地区块，重复执行结果相同
+func (s *Service) applyRemoteTx(b *Block, remote *PostArtifactResponseData) (err error) {
+	persistMutex.Lock()
+	defer persistMutex.Unlock()
+
+	wasFinal := s.isFinal(b.State)
+	b.TxId = remote.TxId
+	b.ClauseIndex = remote.ClauseIndex
+	b.BlockNumber = 0
+	b.BlockTimestamp = 0
+	b.Reverted = false
+	b.Confirmations = 0
+	b.State = BlockStatePosted
+	_, err = s.dbEngine.ID(b.Id).Cols("state", "tx_id", "clause_index", "block_number", "block_timestamp", "reverted", "confirmations").Update(b)
+	if err != nil {
+		log.Error("%+v", err.Error())
+		return
+	}
+	if !wasFinal && s.isFinal(b.State) {
+		s.SuccessChan <- b
+	}
+	return
+}
+
+// ============滞留区块============
+// 进程在接口返回与本地提交之间崩溃时，区块会停留在待抢占/待上链，
+// 按原请求编号（命令id）重发一次原报文取回平台结果并修复本地状态
+
+type StuckAction string
+
+const (
+	StuckRepaired StuckAction = "REPAIRED" //平台已成功，已按平台结果修复
+	StuckRequeued StuckAction = "REQUEUED" //平台处理中或无记录，重新执行命令继续轮询
+	StuckFailed   StuckAction = "FAILED"   //平台处理失败，命令标记为失败
+	StuckSkipped  StuckAction = "SKIPPED"  //命令运行中
+	StuckError    StuckAction = "ERROR"    //查询或修复出错
+)
+
+type StuckCommand struct {
+	CommandId    int64       `json:"command_id"`
+	Cmd          string      `json:"cmd"`
+	Blocks       int         `json:"blocks"`        //滞留的区块数量
+	RemoteStatus string      `json:"remote_status"` //平台状态
+	Action       StuckAction `json:"action"`
+	Error        string      `json:"error,omitempty"`
+}
+
+type StuckReport struct {
+	Started   time.Time       `json:"started"`
+	Finished  time.Time       `json:"finished"`
+	Threshold time.Duration   `json:"threshold"`
+	Commands  []*StuckCommand `json:"commands"`
+}
+
+func (self *StuckReport) String() string {
+	count := make(map[StuckAction]int)
+	for _, v := range self.Commands {
+		count[v.Action]++
+	}
+	return fmt.Sprintf("stuck commands:%d repaired:%d requeued:%d failed:%d skipped:%d error:%d",
+		len(self.Commands), count[StuckRepaired], count[StuckRequeued], count[StuckFailed], count[StuckSkipped], count[StuckError])
+}
+
+// 查找停留在待抢占/待上链超过 threshold 的区块，按所属命令到平台取回结果并修复
+//  可重复执行：已修复的区块离开滞留状态，不会被再次处理
+func (s *Service) ReconcileStuck(ctx context.Context, threshold time.Duration) (report *StuckReport, err error) {
+	report = &StuckReport{Started: time.Now(), Threshold: threshold}
+	defer func() {
+		report.Finished = time.Now()
+		log.Info("reconcile stuck finished: %s", report.String())
+	}()
+
+	session := s.dbEngine.NewSession()
+	defer session.Close()
+
+	var blocks []*Block
+	err = session.In("state", BlockStateToOccupy, BlockStateToPost).And("updated<?", time.Now().Add(-threshold)).Cols("id", "current_command_id").Find(&blocks)
+	if err != nil {
+		log.Error("%+v", err.Error())
+		return
+	}
+	var commandIds []int64
+	blockCount := make(map[int64]int)
+	for _, v := range blocks {
+		if _, ok := blockCount[v.CurrentCommandId]; !ok {
+			commandIds = append(commandIds, v.CurrentCommandId)
+		}
+		blockCount[v.CurrentCommandId]++
+	}
+
+	for _, v := range commandIds {
+		if err = ctx.Err(); err != nil {
+			return
+		}
+		item := &StuckCommand{CommandId: v, Blocks: blockCount[v]}
+		report.Commands = append(report.Commands, item)
+		if _, loaded := s.RunningCommandIds.LoadOrStore(v, true); loaded {
+			item.Action = StuckSkipped
+			continue
+		}
+		//其他副本可能仍在执行该命令，与 RetryCommand 一样先在数据库中认领
+		claimed, claimErr := s.claimCommand(session, v)
+		if claimErr != nil || !claimed {
+			s.RunningCommandIds.Delete(v)
+			item.Action = StuckSkipped
+			if claimErr != nil {
+				item.Action = StuckError
+				item.Error = claimErr.Error()
+			}
+			continue
+		}
+		requeued, repairErr := s.repairStuckCommand(ctx, item)
+		if repairErr != nil {
+			item.Action = StuckError
+			item.Error = repairErr.Error()
+			//已认领的命令标记为失败，可通过 RetryCommand 重试
+			_, failErr := s.dbEngine.ID(v).Update(&CommandModel{Error: repairErr.Error(), State: CommandStateOfFail})
+			if failErr != nil {
+				log.Error("%+v", failErr.Error())
+			}
+		}
+		if !requeued {
+			s.RunningCommandIds.Delete(v)
+		}
+	}
+	return
+}
+
+// 修复单个滞留命令，requeued 为 true 时命令已重新进入执行通道
+func (s *Service) repairStuckCommand(ctx context.Context, item *StuckCommand) (requeued bool, err error) {
+	session := s.dbEngine.NewSession()
+	defer session.Close()
+
+	cmd, err := GetCommandById(session, newCommandContext(s.config.CommandExpireDuration), item.CommandId)
+	if err != nil {
+		return
+	}
+
+	switch c := cmd.(type) {
+	case *OccupyVidCommand:
+		item.Cmd = Command_Occupy_Vid
+		var request *OccupyVidRequest
+		request, err = c.loadRequest(s)
+		if err != nil {
+			return
+		}
+		var response *OccupyVidResponse
+		response, err = OccupyVidOnce(ctx, s.config, s.Token, request)
+		if err != nil {
+			return
+		}
+		item.RemoteStatus = response.Status
+		if response.Status != string(CommandStateOfSuccess) {
+			break
+		}
+		var newCommands []ICommand
+		var uid string
+		uid, err = s.Accounts.Uid(c.account)
+		if err != nil {
+			return
+		}
+		newCommands, err = c.next(s.dbEngine.NewSession(), s.vidGenerator(), uid, response)
+		if err != nil {
+			return
+		}
+		s.publishBlocks(EventOccupied, c.id, c.occupied())
+		for _, v := range newCommands {
+			s.RunningCommandIds.Store(v.GetId(), true)
+			s.CommandChan <- v
+		}
+		item.Action = StuckRepaired
+		return
+
+	case *PostArtifactCommand:
+		item.Cmd = Command_Post_Artifact
+		var request *PostArtifactRequest
+		request, err = c.loadRequest(s)
+		if err != nil {
+			return
+		}
+		var response *PostArtifactResponse
+		response, err = PostArtifactOnce(ctx, s.config, s.Token, request)
+		if err != nil {
+			return
+		}
+		item.RemoteStatus = response.Status
+		switch response.Status {
+		case string(CommandStateOfSuccess):
+			var posted []*Block
+			posted, err = c.next(s.dbEngine.NewSession(), s.postSuccessChan(), response)
+			if err != nil {
+				return
+			}
+			s.publishBlocks(EventPosted, c.id, posted)
+			item.Action = StuckRepaired
+			return
+		case "PROCESSING":
+		default:
+			_, err = s.dbEngine.ID(c.id).Update(&CommandModel{Error: response.Status, State: CommandStateOfFail})
+			if err != nil {
+				log.Error("%+v", err.Error())
+				return
+			}
+			s.publishCommandFailed(c.id, c.account, c.blocks, response.Status)
+			item.Action = StuckFailed
+			return
+		}
+
+	default:
+		err = fmt.Errorf("invalid command id:%d", item.CommandId)
+		return
+	}
+
+	//平台仍在处理（重发的报文也可能刚被平台受理为首次提交），重新执行原命令继续轮询
+	item.Action = StuckRequeued
+	s.CommandChan <- cmd
+	requeued = true
+	return
+}
@@ -0,0 +1,224 @@
+package vechain
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+//上链接口桩：requestNo 为 ok 的命令返回 results 中的 txList，其余返回 status
+func newPostTestServer(t *testing.T, status string, results string) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		request := new(PostArtifactRequest)
+		if err := json.NewDecoder(r.Body).Decode(request); err != nil {
+			t.Errorf("invalid request body: %v", err)
+		}
+		if request.RequestNo == "ok" {
+			fmt.Fprintf(w, `{"code":1,"data":{"requestNo":"%s","status":"SUCCESS","txList":%s}}`, request.RequestNo, results)
+			return
+		}
+		fmt.Fprintf(w, `{"code":1,"data":{"requestNo":"%s","status":"%s"}}`, request.RequestNo, status)
+	}))
+}
+
+func insertTestCommand(t *testing.T, s *Service, cmd string, state CommandState, payload string) int64 {
+	cm := &CommandModel{Cmd: cmd, State: state, Payload: payload}
+	if _, err := s.dbEngine.Insert(cm); err != nil {
+		t.Fatal(err)
+	}
+	return cm.Id
+}
+
+//把命令的更新时间提前到超时之前，模拟执行命令的进程已退出
+func expireTestCommand(t *testing.T, s *Service, id int64) {
+	expired := &CommandModel{CommonModel: CommonModel{Updated: time.Now().Add(-2 * s.config.CommandExpireDuration)}}
+	if _, err := s.dbEngine.ID(id).NoAutoTime().Cols("updated").Update(expired); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestReconcile(t *testing.T) {
+	server := newPostTestServer(t, "PROCESSING", `[
+		{"txid":"0x01","clauseIndex":"0","vid":"V1","dataHash":"H1"},
+		{"txid":"0x09","clauseIndex":"0","vid":"V2","dataHash":"H2"},
+		{"txid":"0x03","clauseIndex":"0","vid":"V3","dataHash":"HX"},
+		{"txid":"0x05","clauseIndex":"0","vid":"V5","dataHash":"H5"}]`)
+	defer server.Close()
+	s, cleanup := newTestService(t, &VechainConfig{SiteUrl: server.URL + "/"})
+	defer cleanup()
+	s.Token = staticToken("token")
+
+	ok := insertTestCommand(t, s, Command_Post_Artifact, CommandStateOfSuccess, `{"requestNo":"ok","uid":"U0"}`)
+	processing := insertTestCommand(t, s, Command_Post_Artifact, CommandStateOfSuccess, `{"requestNo":"processing","uid":"U0"}`)
+	insertTestCommand(t, s, Command_Post_Artifact, CommandStateOfFail, `{"requestNo":"ok","uid":"U0"}`)
+	blocks := insertTestBlocks(t, s,
+		&Block{Hash: "H1", Vid: "V1", State: BlockStatePosted, CurrentCommandId: ok},
+		&Block{Hash: "H2", Vid: "V2", TxId: "0x02", State: BlockStatePosted, CurrentCommandId: ok},
+		&Block{Hash: "H3", Vid: "V3", TxId: "0x03", State: BlockStatePosted, CurrentCommandId: ok},
+		&Block{Hash: "H4", Vid: "V4", TxId: "0x04", State: BlockStatePosted, CurrentCommandId: ok},
+		&Block{Hash: "H5", Vid: "V5", TxId: "0x05", State: BlockStatePosted, CurrentCommandId: ok},
+		&Block{Hash: "H6", Vid: "V6", TxId: "0x06", State: BlockStatePosted, CurrentCommandId: processing},
+	)
+
+	report, err := s.Reconcile(context.Background(), true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if report.Checked != 5 || report.Fixed != 2 || len(report.Errors) != 1 {
+		t.Errorf("unexpected report %s %v", report.String(), report.Errors)
+	}
+	issues := make(map[string]ReconcileIssue)
+	for _, v := range report.Items {
+		issues[v.Vid] = v.Issue
+	}
+	expect := map[string]ReconcileIssue{
+		"V1": ReconcileMissingTxId,
+		"V2": ReconcileTxIdMismatch,
+		"V3": ReconcileDataHashMismatch,
+		"V4": ReconcileUnknownVid,
+	}
+	if fmt.Sprint(issues) != fmt.Sprint(expect) {
+		t.Errorf("expect %v, got %v", expect, issues)
+	}
+	if b := getTestBlock(t, s, blocks[0].Id); b.TxId != "0x01" {
+		t.Errorf("missing txid not fixed: %+v", *b)
+	}
+	if b := getTestBlock(t, s, blocks[1].Id); b.TxId != "0x09" {
+		t.Errorf("mismatched txid not fixed: %+v", *b)
+	}
+	if b := getTestBlock(t, s, blocks[2].Id); b.TxId != "0x03" {
+		t.Errorf("data hash mismatch should only be reported: %+v", *b)
+	}
+}
+
+func TestReconcileStuck(t *testing.T) {
+	cases := []struct {
+		cmd      string
+		status   string
+		action   StuckAction
+		requeued bool         //原命令重新进入执行通道
+		next     int          //next 生成的新命令数
+		state    BlockState   //修复后的区块状态
+		cmdState CommandState //修复后的命令状态
+	}{
+		{Command_Occupy_Vid, "GENERATING", StuckRequeued, true, 0, BlockStateToOccupy, CommandStateOfGenerating},
+		{Command_Occupy_Vid, "SUCCESS", StuckRepaired, false, 1, BlockStateToPost, CommandStateOfSuccess},
+		{Command_Post_Artifact, "PROCESSING", StuckRequeued, true, 0, BlockStateToPost, CommandStateOfGenerating},
+		{Command_Post_Artifact, "SUCCESS", StuckRepaired, false, 0, BlockStatePosted, CommandStateOfSuccess},
+		{Command_Post_Artifact, "FAILURE", StuckFailed, false, 0, BlockStateToPost, CommandStateOfFail},
+	}
+	for _, c := range cases {
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			switch r.URL.Path {
+			case "/v1/vid/occupy":
+				fmt.Fprintf(w, `{"code":1,"data":{"requestNo":"1","status":"%s","successList":["V1"]}}`, c.status)
+			case "/v1/artifacts/hashinfo/create":
+				fmt.Fprintf(w, `{"code":1,"data":{"requestNo":"1","status":"%s","txList":[{"txid":"0x01","clauseIndex":"0","vid":"V1","dataHash":"H1"}]}}`, c.status)
+			}
+		}))
+		s, cleanup := newTestService(t, &VechainConfig{SiteUrl: server.URL + "/"})
+		s.Token = staticToken("token")
+
+		state := BlockStateToOccupy
+		if c.cmd == Command_Post_Artifact {
+			state = BlockStateToPost
+		}
+		id := insertTestCommand(t, s, c.cmd, CommandStateOfGenerating, `{"requestNo":"1","uid":"U0","vidList":["V1"]}`)
+		expireTestCommand(t, s, id)
+		blocks := insertTestBlocks(t, s, &Block{Hash: "H1", Vid: "V1", State: state, CurrentCommandId: id})
+
+		report, err := s.ReconcileStuck(context.Background(), -time.Minute)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(report.Commands) != 1 || report.Commands[0].Action != c.action || report.Commands[0].RemoteStatus != c.status {
+			t.Errorf("%s %s: unexpected report %s", c.cmd, c.status, report.String())
+		}
+		requeued := false
+		var next int
+		for len(s.CommandChan) > 0 {
+			if cmd := <-s.CommandChan; cmd.GetId() == id {
+				requeued = true
+			} else {
+				next++
+			}
+		}
+		if requeued != c.requeued || next != c.next {
+			t.Errorf("%s %s: expect requeued:%v next:%d, got requeued:%v next:%d", c.cmd, c.status, c.requeued, c.next, requeued, next)
+		}
+		if _, running := s.RunningCommandIds.Load(id); running != c.requeued {
+			t.Errorf("%s %s: running flag should only be kept for requeued command", c.cmd, c.status)
+		}
+		if b := getTestBlock(t, s, blocks[0].Id); b.State != c.state {
+			t.Errorf("%s %s: expect block state %d, got %d", c.cmd, c.status, c.state, b.State)
+		}
+		cm := new(CommandModel)
+		if _, err = s.dbEngine.ID(id).Get(cm); err != nil || cm.State != c.cmdState {
+			t.Errorf("%s %s: expect command state %s, got %s %v", c.cmd, c.status, c.cmdState, cm.State, err)
+		}
+		cleanup()
+		server.Close()
+	}
+}
+
+func TestReconcileStuck_PublishPosted(t *testing.T) {
+	server := newPostTestServer(t, "PROCESSING", `[{"txid":"0x01","clauseIndex":"0","vid":"V1","dataHash":"H1"}]`)
+	defer server.Close()
+	s, cleanup := newTestService(t, &VechainConfig{SiteUrl: server.URL + "/"})
+	defer cleanup()
+	s.Token = staticToken("token")
+	events, cancel := s.Subscribe(EventFilter{Types: []EventType{EventPosted}})
+	defer cancel()
+
+	id := insertTestCommand(t, s, Command_Post_Artifact, CommandStateOfGenerating, `{"requestNo":"ok","uid":"U0"}`)
+	expireTestCommand(t, s, id)
+	blocks := insertTestBlocks(t, s,
+		&Block{Hash: "H1", Vid: "V1", State: BlockStateToPost, CurrentCommandId: id},
+		&Block{Hash: "H2", Vid: "V2", State: BlockStateToPost, CurrentCommandId: id},
+	)
+	if _, err := s.ReconcileStuck(context.Background(), -time.Minute); err != nil {
+		t.Fatal(err)
+	}
+	//平台只返回了 V1，只有 H1 已受理
+	if event := <-events; event.Block.Hash != "H1" || len(events) != 0 {
+		t.Errorf("expect only H1 posted, got %+v and %d more", event.Block, len(events))
+	}
+	if b := getTestBlock(t, s, blocks[1].Id); b.State != BlockStateToPost {
+		t.Errorf("H2 should stay to post, got %d", b.State)
+	}
+}
+
+//其他副本仍在执行的命令（执行中且未超时）认领失败，不向平台重发
+func TestReconcileStuck_RunningElsewhere(t *testing.T) {
+	var calls int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&calls, 1)
+		w.Write([]byte(`{"code":1,"data":{"requestNo":"1","status":"SUCCESS","successList":["V1"]}}`))
+	}))
+	defer server.Close()
+	s, cleanup := newTestService(t, &VechainConfig{SiteUrl: server.URL + "/"})
+	defer cleanup()
+	s.Token = staticToken("token")
+
+	id := insertTestCommand(t, s, Command_Occupy_Vid, CommandStateOfGenerating, `{"requestNo":"1","uid":"U0","vidList":["V1"]}`)
+	insertTestBlocks(t, s, &Block{Hash: "H1", Vid: "V1", State: BlockStateToOccupy, CurrentCommandId: id})
+
+	report, err := s.ReconcileStuck(context.Background(), -time.Minute)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(report.Commands) != 1 || report.Commands[0].Action != StuckSkipped {
+		t.Errorf("expect command skipped, got %s", report.String())
+	}
+	if calls != 0 || len(s.CommandChan) != 0 {
+		t.Errorf("expect no resend and no command, got calls:%d commands:%d", calls, len(s.CommandChan))
+	}
+	if _, running := s.RunningCommandIds.Load(id); running {
+		t.Error("running flag should be cleared for skipped command")
+	}
+}
@@ -5,7 +5,9 @@ import (
 	"fmt"
 	"math"
 	"runtime/debug"
+	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/myafeier/log"
@@ -19,33 +21,149 @@ func init() {
 	log.SetLogLevel(log.DEBUG)
 }
 
-// NewService 新建服务
-func InitService(engine *xorm.Engine, config *VechainConfig) {
-	if Daemon == nil {
-		Daemon = &Service{dbEngine: engine}
-		Daemon.Token = NewDefaultToken(config)
-		Daemon.CommandChan = make(chan ICommand, 100)
-		Daemon.SuccessChan = make(chan *Block, 10000)
-		Daemon.dbEngine = engine
-		Daemon.config = config
+// 初始化服务：校验配置、检查节点网络、自动创建子账户，成功后设置 Daemon 并启动守护进程
+//  配置无效时返回 ConfigErrors，列出所有问题；初始化成功后才设置 Daemon
+func InitService(engine *xorm.Engine, config *VechainConfig) (err error) {
+	s := Daemon
+	if s == nil {
+		s, err = NewService(engine, config)
+		if err != nil {
+			return
+		}
+		defer func() {
+			//未成功初始化的服务不再使用，停止 token 的后台刷新和凭据监听
+			if err != nil {
+				s.closeToken()
+			}
+		}()
+		if s.Thor != nil {
+			ctx, cancel := context.WithTimeout(context.Background(), ThorNetworkCheckTimeout)
+			err = config.checkThorNetwork(ctx, s.Thor)
+			cancel()
+			if err != nil {
+				log.Error("%+v", err.Error())
+				return
+			}
+		}
+	} else {
+		initTable(engine.NewSession())
+	}
+	if config.AutoCreateSubAccount && config.UserIdOfYuanZhiLian == "" {
+		config.UserIdOfYuanZhiLian, err = s.EnsureSubAccount(context.Background(), DefaultAccountKey, config.SubAccountName)
+		if err != nil {
+			return
+		}
+		s.Accounts.Register(DefaultAccountKey, config.UserIdOfYuanZhiLian)
+		log.Info("use sub account uid:%s", config.UserIdOfYuanZhiLian)
+	}
+	Daemon = s
+	go s.StartDaemon()
+	return
+}
+
+// NewService 新建服务：校验配置、建表、加载子账户，不检查节点网络、不自动创建子账户、不启动守护进程，也不设置 Daemon
+//  供查询、导出等只读或管理用途（如 vechainctl status）使用；提交的命令只有在守护进程中才会执行，需提交时使用 InitService
+func NewService(engine *xorm.Engine, config *VechainConfig) (s *Service, err error) {
+	config.SetDefaults()
+	err = config.Validate()
+	if err != nil {
+		log.Error(err.Error())
+		return
+	}
+	if config.CredentialsFile != "" && config.Credentials == nil {
+		var credentials *FileCredentials
+		credentials, err = NewFileCredentials(config.CredentialsFile)
+		if err != nil {
+			return
+		}
+		config.Credentials = credentials
+	}
+	s = &Service{dbEngine: engine}
+	switch config.TokenStore {
+	case TokenStoreOfDatabase:
+		s.Token = NewSharedToken(config, NewDBTokenStore(engine, config.credentials()))
+	case TokenStoreOfFile:
+		s.Token = NewSharedToken(config, NewFileTokenStore(config.TokenFile))
+	default:
+		s.Token = NewDefaultToken(config)
+	}
+	defer func() {
+		if err != nil {
+			s.closeToken()
+			s = nil
+		}
+	}()
+	s.CommandChan = make(chan ICommand, 100)
+	s.SuccessChan = make(chan *Block, 10000)
+	s.vidPoolSignal = make(chan struct{}, 1)
+	s.config = config
+	if config.ThorNodeUrl != "" {
+		s.Thor = NewThorClient(config.ThorNodeUrl)
+	}
+	s.VidGenerator, err = NewVidGenerator(config, engine)
+	if err != nil {
+		log.Error("%+v", err.Error())
+		return
 	}
 	initTable(engine.NewSession())
-	go Daemon.StartDaemon()
+	s.Accounts = NewAccountRegistry()
+	err = s.loadAccounts()
+	return
+}
+
+//停止 token 的后台刷新和凭据监听
+func (s *Service) closeToken() {
+	if closer, ok := s.Token.(interface{ Close() }); ok {
+		closer.Close()
+	}
 }
 
 //提交产品ID和产品HASH，提交上链
 var submitMutex sync.Mutex
 
-// 异步提交hash
+// 异步提交hash，使用默认账户
 func AsyncSubmit(hashes []string) (err error) {
+	return AsyncSubmitForAccount(DefaultAccountKey, hashes)
+}
+
+// 异步提交hash，以 account 对应的子账户上链，account 需在配置 SubAccounts 中或已通过 AddSubAccount 登记
+func AsyncSubmitForAccount(account string, hashes []string) (err error) {
+	return Daemon.AsyncSubmitForAccount(account, hashes)
+}
+
+// 异步提交hash，以 account 对应的子账户上链，说明同包级函数 AsyncSubmitForAccount
+func (s *Service) AsyncSubmitForAccount(account string, hashes []string) (err error) {
+	_, err = s.SubmitForAccount(account, hashes)
+	return
+}
+
+// 同 AsyncSubmitForAccount，另返回本次新提交的 hash 数（不含已提交过和已由其他账户提交的 hash）
+func (s *Service) SubmitForAccount(account string, hashes []string) (added int, err error) {
+	_, err = s.Accounts.Uid(account)
+	if err != nil {
+		log.Error("%+v", err.Error())
+		return
+	}
+	if account == DefaultAccountKey {
+		account = ""
+	}
 	submitMutex.Lock()
 	defer submitMutex.Unlock()
-	//过滤已经有命令的产品，
-	restHashes, err := Daemon.filter(hashes)
+	//过滤已经有命令的产品，已由其他账户提交的 hash 在其余 hash 提交后以 AccountConflictError 返回
+	restHashes, err := s.filter(account, hashes)
+	conflict, _ := err.(*AccountConflictError)
+	if conflict != nil {
+		err = nil
+	}
 	if err != nil {
 		log.Error("%+v", err.Error())
 		return
 	}
+	defer func() {
+		if err == nil && conflict != nil {
+			err = conflict
+		}
+	}()
 
 	if restHashes != nil && len(restHashes) > 0 {
 		var blocks []*Block
@@ -54,13 +172,34 @@ func AsyncSubmit(hashes []string) (err error) {
 			b := new(Block)
 			b.Hash = v
 			b.State = BlockStateToOccupy
+			b.Account = account
 			blocks = append(blocks, b)
 		}
-		err = Daemon.dispatchVid(blocks)
+		err = s.dispatchVid(account, blocks)
 		if err != nil {
 			log.Error("%+v", err.Error())
 			return
 		}
+		added = len(blocks)
+	}
+	return
+}
+
+//hash 的最大长度，与 vechain_block.hash 字段长度一致
+const HashMaxLength = 100
+
+// 检查提交的 hash：不能为空白，不能超过 HashMaxLength
+func ValidateHashes(hashes []string) (err error) {
+	if len(hashes) == 0 {
+		return fmt.Errorf("hashes is required")
+	}
+	for k, v := range hashes {
+		if strings.TrimSpace(v) == "" {
+			return fmt.Errorf("hashes[%d] is blank", k)
+		}
+		if len(v) > HashMaxLength {
+			return fmt.Errorf("hashes[%d] is longer than %d", k, HashMaxLength)
+		}
 	}
 	return
 }
@@ -68,9 +207,33 @@ func GetExplorUrlByTxid(txid string) string {
 	return BlockChainExploreLink(txid, Daemon.config)
 }
 
-//获取产品的区块信息
+var ErrBlockNotFound = fmt.Errorf("block not found")
+
+var ErrHashOwnedByOtherAccount = fmt.Errorf("hash owned by other account")
+
+// AccountConflictError 提交的 hash 已由其他账户提交，这些 hash 不会提交，其余 hash 照常提交
+//  可用 errors.Is(err, ErrHashOwnedByOtherAccount) 判断
+type AccountConflictError struct {
+	Hashes []string
+}
+
+func (self *AccountConflictError) Error() string {
+	return fmt.Sprintf("%s: %s", ErrHashOwnedByOtherAccount.Error(), strings.Join(self.Hashes, ","))
+}
+
+func (self *AccountConflictError) Unwrap() error {
+	return ErrHashOwnedByOtherAccount
+}
+
+//获取产品的区块信息，不存在时返回的错误包装 ErrBlockNotFound
 func GetBlockInfoByUuid(uuid string) (b *Block, err error) {
-	session := Daemon.dbEngine.NewSession()
+	return Daemon.GetBlockInfoByUuid(uuid)
+}
+
+//获取产品的区块信息，说明同包级函数 GetBlockInfoByUuid
+func (s *Service) GetBlockInfoByUuid(uuid string) (b *Block, err error) {
+	session := s.dbEngine.NewSession()
+	defer session.Close()
 	b = new(Block)
 	has, err := session.Where("hash=?", uuid).Get(b)
 	if err != nil {
@@ -79,9 +242,9 @@ func GetBlockInfoByUuid(uuid string) (b *Block, err error) {
 	}
 	log.Debug("blockInfo: %+v", *b)
 	if !has {
-		err = fmt.Errorf("%s not found", uuid)
+		err = fmt.Errorf("%w: %s", ErrBlockNotFound, uuid)
 	}
-	b.GetExplorUrl()
+	b.ExplorUrl = BlockChainExploreLink(b.TxId, s.config)
 	return
 }
 
@@ -90,19 +253,35 @@ func AddPostArtifactObserver(observer IObserver) {
 	Daemon.Observers = append(Daemon.Observers, observer)
 }
 
+//添加滞留区块对账报告观察者
+func AddReconcileObserver(observer IReconcileObserver) {
+	Daemon.ReconcileObservers = append(Daemon.ReconcileObservers, observer)
+}
+
 // Service 服务
 type Service struct {
-	RunningCommandIds sync.Map      //运行中的命令
-	CommandChan       chan ICommand //命令执行通道
-	SuccessChan       chan *Block   //执行成功后的处理通道
-	Observers         []IObserver   //观察者
-	Token             IToken
-	dbEngine          *xorm.Engine
-	config            *VechainConfig
+	RunningCommandIds  sync.Map             //运行中的命令
+	CommandChan        chan ICommand        //命令执行通道
+	SuccessChan        chan *Block          //执行成功后的处理通道
+	Observers          []IObserver          //观察者
+	ReconcileObservers []IReconcileObserver //滞留区块对账报告观察者
+	Token              IToken
+	Thor               *ThorClient      //链上确认客户端，未配置节点时为空
+	Accounts           *AccountRegistry //子账户
+	VidGenerator       VidGenerator     //vid 生成方式，InitService 按配置 VidStrategy 设置
+	events             eventHub         //事件订阅者
+	vidPoolSignal      chan struct{}    //提交分配了池中的 vid 后通知检查水位
+	confirming         int32            //链上确认检查运行中
+	reconciling        int32            //滞留区块修复运行中
+	dbEngine           *xorm.Engine
+	config             *VechainConfig
 }
 
 func (s *Service) StartDaemon() {
-	ticket := time.NewTicker(CheckFailDuration)
+	ticket := time.NewTicker(s.config.CheckFailDuration)
+	confirmTicket := time.NewTicker(ConfirmCheckDuration)
+	stuckTicket := time.NewTicker(StuckCheckDuration)
+	vidPoolTicket := time.NewTicker(VidPoolCheckDuration)
 	for {
 		select {
 		case cmd := <-s.CommandChan:
@@ -119,6 +298,7 @@ func (s *Service) StartDaemon() {
 			}()
 		case block := <-s.SuccessChan:
 			log.Debug("receive success block。。。")
+			s.publishBlocks(EventSucceeded, block.CurrentCommandId, []*Block{block})
 			go func(b *Block) {
 				log.Debug("run watcher:%s", b.Hash)
 				defer func() {
@@ -141,13 +321,67 @@ func (s *Service) StartDaemon() {
 				}()
 				//s.checkFail()
 			}()
+		case <-confirmTicket.C:
+			go func() {
+				defer func() {
+					if r := recover(); r != nil {
+						log.Debug("Recover: %+v", r)
+						debug.PrintStack()
+					}
+				}()
+				runExclusive(&s.confirming, s.checkConfirm)
+			}()
+		case <-stuckTicket.C:
+			go func() {
+				defer func() {
+					if r := recover(); r != nil {
+						log.Debug("Recover: %+v", r)
+						debug.PrintStack()
+					}
+				}()
+				runExclusive(&s.reconciling, func() {
+					report, err := s.ReconcileStuck(context.Background(), StuckThreshold)
+					if err != nil {
+						log.Error("%+v", err.Error())
+					}
+					for _, v := range s.ReconcileObservers {
+						v.Report(report)
+					}
+				})
+			}()
+		case <-vidPoolTicket.C:
+			s.signalVidPool()
+		case <-s.vidPoolSignal:
+			go func() {
+				defer func() {
+					if r := recover(); r != nil {
+						log.Debug("Recover: %+v", r)
+						debug.PrintStack()
+					}
+				}()
+				_, err := s.FillVidPool()
+				if err != nil {
+					log.Error("%+v", err.Error())
+				}
+			}()
 		}
 	}
 }
 
+//上一次运行还未结束时跳过本次，避免同一批区块被并发处理、观察者重复触发
+func runExclusive(running *int32, fn func()) {
+	if !atomic.CompareAndSwapInt32(running, 0, 1) {
+		log.Debug("previous run not finished, skip")
+		return
+	}
+	defer atomic.StoreInt32(running, 0)
+	fn()
+}
+
 func (s *Service) checkFail() {
 	var failIds []int64
 	session := s.dbEngine.NewSession()
+	defer session.Close()
 	err := session.Table("vechain_command").Where("state!=?", CommandStateOfSuccess).Cols("id").Find(&failIds)
 	if err != nil {
 		log.Error("%+v", err.Error())
@@ -160,10 +394,8 @@ func (s *Service) checkFail() {
 				log.Debug("命令：%d已在运行中，跳过!", v)
 				continue
 			}
-			ctx := context.TODO()
-			ctx, _ = context.WithDeadline(ctx, time.Now().Add(CommandExpireDuration))
 			var cmd ICommand
-			cmd, err = GetCommandById(session, ctx, v)
+			cmd, err = GetCommandById(session, newCommandContext(s.config.CommandExpireDuration), v)
 			if err != nil {
 				log.Error("%+v", err.Error())
 				return
@@ -174,79 +406,122 @@ func (s *Service) checkFail() {
 	}
 }
 
-func (s *Service) dispatchVid(blocks []*Block) (err error) {
+func (s *Service) dispatchVid(account string, blocks []*Block) (err error) {
 	persistMutex.Lock()
 	defer persistMutex.Unlock()
 	session := s.dbEngine.NewSession()
+	defer session.Close()
 	err = session.Begin()
 	if err != nil {
 		log.Error("%+v", err.Error())
 		return
 	}
 
+	var cmds []ICommand
+	var postCmds []ICommand //分配了池中 vid 的上链命令
+
+	//事务提交后再发送命令和事件，回滚时不会有已发布但未落库的区块
 	defer func() {
 		if err != nil {
-			err = session.Rollback()
-		} else {
-			err = session.Commit()
+			session.Rollback()
+			return
+		}
+		err = session.Commit()
+		if err != nil {
+			log.Error("%+v", err.Error())
+			return
+		}
+		for _, v := range postCmds {
+			s.publishBlocks(EventOccupied, v.GetId(), v.GetBlocks())
+		}
+		if len(postCmds) > 0 {
+			s.signalVidPool()
+		}
+		for _, v := range cmds {
+			s.RunningCommandIds.Store(v.GetId(), true)
+			s.CommandChan <- v
 		}
 	}()
 
-	//按照100每组进行分组，形成command
-	hashLength := len(blocks)
-	var datas [][]*Block
-	if hashLength > ItemAmountPerRequest {
-		n := int(math.Ceil(float64(hashLength) / float64(ItemAmountPerRequest)))
-		for i := 0; i < n; i++ {
-			lastIndex := (i + 1) * ItemAmountPerRequest
-			if hashLength < lastIndex {
-				lastIndex = hashLength
+	//先分配池中已抢占的 vid，直接生成上链命令
+	pooled, blocks, err := s.takePooledVids(session, blocks)
+	if err != nil {
+		log.Error("%+v", err.Error())
+		return
+	}
+	if len(pooled) > 0 {
+		var uid string
+		uid, err = s.Accounts.Uid(account)
+		if err != nil {
+			log.Error("%+v", err.Error())
+			return
+		}
+		for _, v := range chunkBlocks(pooled, s.config.ItemAmountPerRequest) {
+			var cmd ICommand
+			cmd, err = NewPostArtifactCommand(session, newCommandContext(s.config.CommandExpireDuration), account, uid, v)
+			if err != nil {
+				log.Error("%+v", err.Error())
+				return
 			}
-			datas = append(datas, blocks[i*ItemAmountPerRequest:lastIndex])
+			postCmds = append(postCmds, cmd)
+			cmds = append(cmds, cmd)
 		}
-		//datas = append(datas, blocks[(n*ItemAmountPerRequest-1):hashLength])
-	} else {
-		datas = append(datas, blocks)
 	}
 
-	var cmds []ICommand
-
-	for _, v := range datas {
-		ctx := context.TODO()
-		ctx, _ = context.WithDeadline(ctx, time.Now().Add(CommandExpireDuration))
+	//按照 ItemAmountPerRequest 每组进行分组，形成command
+	for _, v := range chunkBlocks(blocks, s.config.ItemAmountPerRequest) {
 		var cmd ICommand
-		cmd, err = NewOccupyVidCommand(session, ctx, v)
+		cmd, err = NewOccupyVidCommand(session, newCommandContext(s.config.CommandExpireDuration), s.vidGenerator(), account, v)
 		if err != nil {
 			log.Error("%+v", err.Error())
 			return
 		}
 		cmds = append(cmds, cmd)
 	}
-	if cmds != nil && len(cmds) > 0 {
-		for _, v := range cmds {
-			s.CommandChan <- v
-			s.RunningCommandIds.Store(v.GetId(), true)
+	return
+}
+
+//按 size 分组
+func chunkBlocks(blocks []*Block, size int) (chunks [][]*Block) {
+	hashLength := len(blocks)
+	if hashLength == 0 {
+		return
+	}
+	n := int(math.Ceil(float64(hashLength) / float64(size)))
+	for i := 0; i < n; i++ {
+		lastIndex := (i + 1) * size
+		if hashLength < lastIndex {
+			lastIndex = hashLength
 		}
+		chunks = append(chunks, blocks[i*size:lastIndex])
 	}
-
 	return
 }
 
-//过滤已经存在的block
-func (s *Service) filter(hashes []string) (restIds []string, err error) {
+//过滤已经存在的block，已由其他账户提交的 hash 以 AccountConflictError 返回，此时 restIds 仍然有效
+func (s *Service) filter(account string, hashes []string) (restIds []string, err error) {
 	var existBlocks []*Block
 	session := s.dbEngine.NewSession()
+	defer session.Close()
 	err = session.In("hash", hashes).Find(&existBlocks)
 	if err != nil {
 		log.Error("%+v", err.Error())
 		return
 	}
 	var existCommandIds = make([]int64, 0)
+	var conflicts []string //已由其他账户提交的 hash
 
 	if existBlocks != nil && len(existBlocks) > 0 {
 		for _, v := range existBlocks {
-			if v.State == BlockStatePosted {
+			if accountKey(v.Account) != accountKey(account) {
+				log.Error("%s 已由账户 %s 提交，跳过!", v.Hash, accountKey(v.Account))
+				conflicts = append(conflicts, v.Hash)
+			} else if s.isFinal(v.State) {
 				s.SuccessChan <- v
+			} else if v.State.Confirming() {
+				log.Debug("%s 等待链上确认，跳过!", v.Hash)
+			} else if v.State == BlockStateReverted {
+				log.Error("%s 上链交易 %s 已被回滚", v.Hash, v.TxId)
 			} else {
 				exist := false
 				for _, vv := range existCommandIds {
@@ -273,10 +548,8 @@ func (s *Service) filter(hashes []string) (restIds []string, err error) {
 				log.Debug("命令：%d已在运行中，跳过!", v)
 				continue
 			}
-			ctx := context.TODO()
-			ctx, _ = context.WithDeadline(ctx, time.Now().Add(CommandExpireDuration))
 			var cmd ICommand
-			cmd, err = GetCommandById(session, ctx, v)
+			cmd, err = GetCommandById(session, newCommandContext(s.config.CommandExpireDuration), v)
 			if err != nil {
 				log.Error("%+v", err.Error())
 				return
@@ -289,10 +562,229 @@ func (s *Service) filter(hashes []string) (restIds []string, err error) {
 	for _, v := range hashes {
 		restIds = append(restIds, v)
 	}
+	if len(conflicts) > 0 {
+		err = &AccountConflictError{Hashes: conflicts}
+	}
+	return
+}
+
+// 按状态列出命令，state 为空时列出全部，按 id 倒序，limit<=0 时不限制条数
+func (s *Service) ListCommands(state CommandState, limit int) (commands []*CommandModel, err error) {
+	session := s.dbEngine.NewSession()
+	defer session.Close()
+	if state != "" {
+		session.Where("state=?", state)
+	}
+	if limit > 0 {
+		session.Limit(limit)
+	}
+	err = session.Desc("id").Find(&commands)
+	if err != nil {
+		log.Error("%+v", err.Error())
+	}
+	return
+}
+
+var (
+	ErrCommandNotFound  = fmt.Errorf("command not found")
+	ErrCommandSucceeded = fmt.Errorf("command already succeeded")
+	ErrCommandRunning   = fmt.Errorf("command is running")
+)
+
+// 重新执行未成功的命令，已成功或运行中的命令返回 ErrCommandSucceeded/ErrCommandRunning
+//  重新执行前在数据库中认领命令：只有失败的命令，或执行中但超过 CommandExpireDuration 未更新（原进程已退出）的命令
+//  能被改为执行中，多个进程同时重试时只有一个认领成功
+func (s *Service) RetryCommand(id int64) (err error) {
+	session := s.dbEngine.NewSession()
+	defer session.Close()
+	cm := new(CommandModel)
+	has, err := session.ID(id).Get(cm)
+	if err != nil {
+		log.Error("%+v", err.Error())
+		return
+	}
+	if !has {
+		err = ErrCommandNotFound
+		return
+	}
+	if cm.State == CommandStateOfSuccess {
+		err = ErrCommandSucceeded
+		return
+	}
+	if _, loaded := s.RunningCommandIds.LoadOrStore(id, true); loaded {
+		err = ErrCommandRunning
+		return
+	}
+	claimed, err := s.claimCommand(session, id)
+	if err == nil && !claimed { //其他进程正在执行或已认领
+		err = ErrCommandRunning
+	}
+	if err != nil {
+		s.RunningCommandIds.Delete(id)
+		log.Error("%+v", err.Error())
+		return
+	}
+	cmd, err := GetCommandById(session, newCommandContext(s.config.CommandExpireDuration), id)
+	if err != nil {
+		s.RunningCommandIds.Delete(id)
+		log.Error("%+v", err.Error())
+		return
+	}
+	s.CommandChan <- cmd
+	return
+}
+
+//在数据库中认领失败或执行超时的命令并改为执行中，多个副本同时认领时只有一个成功
+func (s *Service) claimCommand(session *xorm.Session, id int64) (claimed bool, err error) {
+	n, err := session.ID(id).And("(state=? OR (state=? AND updated<?))", CommandStateOfFail, CommandStateOfGenerating, time.Now().Add(-s.config.CommandExpireDuration)).
+		Cols("state").Update(&CommandModel{State: CommandStateOfGenerating})
+	if err != nil {
+		log.Error("%+v", err.Error())
+		return
+	}
+	claimed = n > 0
 	return
 }
-func (self *Service) CreateSubAccount(requestNo, account string) (uid string, err error) {
 
-	log.Debug(requestNo)
-	return GenerateSubAccount(requestNo, account, self.config, self.Token)
+// 运行中的命令数
+func (s *Service) RunningCommands() (n int) {
+	s.RunningCommandIds.Range(func(key, value interface{}) bool {
+		n++
+		return true
+	})
+	return
+}
+
+// 以指定请求编号创建子账户，wait 为 true 时等待平台处理完成（最长 SubAccountTimeout）
+func (self *Service) CreateSubAccount(ctx context.Context, requestNo, account string, wait bool) (user *CreateUser, err error) {
+	if wait {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, SubAccountTimeout)
+		defer cancel()
+	}
+	return GenerateSubAccount(ctx, self.config, self.Token, requestNo, account, wait)
+}
+
+// 到链上确认区块的交易，记录区块高度、时间戳、回滚标志及确认深度，并推进区块状态
+func (s *Service) ConfirmBlock(ctx context.Context, b *Block) (err error) {
+	if s.Thor == nil {
+		err = fmt.Errorf("thor node not configured")
+		return
+	}
+	if b.TxId == "" {
+		err = fmt.Errorf("block %s has no txid", b.Hash)
+		return
+	}
+	conf, err := s.Thor.Confirm(ctx, b.TxId)
+	if err != nil {
+		if err != ErrThorPending {
+			log.Error("%+v", err.Error())
+		}
+		return
+	}
+	b.BlockNumber = conf.BlockNumber
+	b.BlockTimestamp = conf.BlockTimestamp
+	b.Reverted = conf.Reverted
+	b.Confirmations = conf.Depth
+	if conf.Reverted {
+		b.State = BlockStateReverted
+	} else if conf.Depth >= s.confirmations() {
+		b.State = BlockStateFinalized
+	} else {
+		b.State = BlockStateIncluded
+	}
+	_, err = s.dbEngine.ID(b.Id).Cols("state", "block_number", "block_timestamp", "reverted", "confirmations").Update(b)
+	if err != nil {
+		log.Error("%+v", err.Error())
+	}
+	return
+}
+
+//定期检查等待链上确认的区块，达到观察者状态时通知观察者
+//  按 id 分页检查全部区块；节点上超过 ConfirmDropTimeout 仍找不到交易的区块视为交易已丢弃，标记为回滚
+func (s *Service) checkConfirm() {
+	if s.Thor == nil {
+		return
+	}
+	var lastId int64
+	for {
+		var blocks []*Block
+		err := s.dbEngine.In("state", BlockStatePosted, BlockStateIncluded).And("tx_id!=''").And("id>?", lastId).Asc("id").Limit(ConfirmAmountPerCheck).Find(&blocks)
+		if err != nil {
+			log.Error("%+v", err.Error())
+			return
+		}
+		for _, v := range blocks {
+			lastId = v.Id
+			s.confirmBlock(v)
+		}
+		if len(blocks) < ConfirmAmountPerCheck {
+			return
+		}
+	}
+}
+
+func (s *Service) confirmBlock(b *Block) {
+	oldState := b.State
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	err := s.ConfirmBlock(ctx, b)
+	cancel()
+	if err == ErrThorNotFound && oldState == BlockStatePosted && time.Since(b.Updated) > ConfirmDropTimeout {
+		err = s.dropBlock(b)
+	}
+	if err != nil {
+		return
+	}
+	if b.State == oldState {
+		return
+	}
+	if b.State == BlockStateReverted {
+		log.Error("%s 上链交易 %s 已被回滚", b.Hash, b.TxId)
+		s.publishBlocks(EventReverted, b.CurrentCommandId, []*Block{b})
+	} else if s.isFinal(b.State) && !s.isFinal(oldState) {
+		s.SuccessChan <- b
+	}
+}
+
+//交易已被节点丢弃，区块标记为回滚
+func (s *Service) dropBlock(b *Block) (err error) {
+	b.State = BlockStateReverted
+	_, err = s.dbEngine.ID(b.Id).And("state=?", BlockStatePosted).Cols("state").Update(&Block{State: BlockStateReverted})
+	if err != nil {
+		log.Error("%+v", err.Error())
+		b.State = BlockStatePosted
+	}
+	return
+}
+
+//触发观察者的状态
+func (s *Service) notifyState() BlockState {
+	if s.Thor == nil {
+		return BlockStatePosted
+	}
+	switch s.config.FinalityLevel {
+	case BlockStatePosted, BlockStateIncluded, BlockStateFinalized:
+		return s.config.FinalityLevel
+	}
+	return BlockStateFinalized
+}
+
+//ToolChain 受理后通知观察者的通道，需要等待链上确认时为空，由确认检查负责通知
+func (s *Service) postSuccessChan() chan *Block {
+	if s.isFinal(BlockStatePosted) {
+		return s.SuccessChan
+	}
+	return nil
+}
+
+//区块是否已达到触发观察者的状态
+func (s *Service) isFinal(state BlockState) bool {
+	return state != BlockStateReverted && state >= s.notifyState()
+}
+
+func (s *Service) confirmations() int64 {
+	if s.config.Confirmations > 0 {
+		return s.config.Confirmations
+	}
+	return FinalityConfirmations
 }
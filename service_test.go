@@ -1,10 +1,12 @@
 package vechain
 
 import (
+	"context"
 	"crypto/sha256"
 	"fmt"
 	_ "github.com/go-sql-driver/mysql"
 	"log"
+	"os"
 	"strconv"
 	"testing"
 	"time"
@@ -12,53 +14,70 @@ import (
 )
 
 const (
-	SiteUrl             string = "https://developer.vetoolchain.cn/api/"
 	DeveloperId         string = "81e1ae4a965571384d96c92cb92b12a6"
 	DeveloperKey        string = "3df2f8ee7cb4a3473194edca859dbb503408edea1c16b47571e7a6724011fe74"
 	VeVid               string = "9227.cn.v"
 	Address             string = "0xd96199f7c65e14f24943e90398a0d09d1cf3b615"
 	UserIdOfYuanZhiLian string = ""
-	ExploreLink         string = "https://insight.vecha.in/#/test/%s"
 )
 
 var config = &VechainConfig{
-	SiteUrl:             SiteUrl,
+	Environment:         EnvironmentTestnet,
 	DeveloperId:         DeveloperId,
 	DeveloperKey:        DeveloperKey,
 	VeVid:               VeVid,
 	Address:             Address,
-	Nonce:               Nonce(),
 	UserIdOfYuanZhiLian: UserIdOfYuanZhiLian,
-	ExploreLink:         ExploreLink,
+	//UserIdOfYuanZhiLian 为空时自动创建
+	AutoCreateSubAccount: true,
+	SubAccountName:       "yuanzhilian",
 }
 var engine *xorm.Engine
 
-func init() {
-	var err error
-	engine, err = xorm.NewEngine("mysql", "test:test@tcp(127.0.0.1:3306)/test?charset=utf8mb4")
-	if err != nil {
-		log.Println(err)
-		return
+//连接平台和 MySQL 的集成测试只在设置了 VECHAIN_TEST_MYSQL（MySQL DSN）时运行，
+//如 VECHAIN_TEST_MYSQL="test:test@tcp(127.0.0.1:3306)/test?charset=utf8mb4"
+func TestMain(m *testing.M) {
+	if dsn := os.Getenv("VECHAIN_TEST_MYSQL"); dsn != "" {
+		var err error
+		engine, err = xorm.NewEngine("mysql", dsn)
+		if err != nil {
+			log.Println(err)
+			os.Exit(1)
+		}
+		engine.SetMaxIdleConns(10)
+		engine.SetMaxOpenConns(100)
+		engine.SetConnMaxLifetime(100 * time.Second)
+		engine.ShowSQL(true)
+		if err = InitService(engine, config); err != nil {
+			log.Println(err)
+			os.Exit(1)
+		}
 	}
-	engine.SetMaxIdleConns(10)
-	engine.SetMaxOpenConns(100)
-	engine.SetConnMaxLifetime(100 * time.Second)
-	engine.ShowSQL(true)
-	go InitService(engine, config)
+	os.Exit(m.Run())
 }
+
+//未配置集成测试环境时跳过
+func requireIntegration(t *testing.T) {
+	if engine == nil {
+		t.Skip("VECHAIN_TEST_MYSQL not set, skip integration test")
+	}
+}
+
 func TestCreateSubAccount(t *testing.T) {
+	requireIntegration(t)
 	account := "yuanzhilian"
 	//requestNo := fmt.Sprintf("T%d", time.Now().Unix())
 	requestNo := "T1597111238"
-	uid, err := Daemon.CreateSubAccount(requestNo, account)
+	user, err := Daemon.CreateSubAccount(context.Background(), requestNo, account, true)
 	if err != nil {
 		t.Error(err)
 		return
 	}
-	t.Log(uid)
+	t.Log(user.Uid)
 }
 
 func TestAsyncSubmit(t *testing.T) {
+	requireIntegration(t)
 	var data []string
 	for i := 1; i < 101; i++ {
 		data = append(data, fmt.Sprintf("0x%X", sha256.Sum256([]byte(strconv.Itoa(i)))))
@@ -0,0 +1,186 @@
+package vechain
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/myafeier/log"
+)
+
+// ============Thor============
+// VeChainThor 节点 REST 接口，用于确认交易已经打包进区块
+
+var (
+	ErrThorNotFound = fmt.Errorf("thor: transaction not found") //节点上找不到该交易
+	ErrThorPending  = fmt.Errorf("thor: transaction pending")   //交易已提交但尚未打包
+)
+
+//交易/回执中的区块信息
+type ThorMeta struct {
+	BlockId        string `json:"blockID"`        //区块ID
+	BlockNumber    int64  `json:"blockNumber"`    //区块高度
+	BlockTimestamp int64  `json:"blockTimestamp"` //区块时间戳（秒）
+	TxId           string `json:"txID,omitempty"`
+	TxOrigin       string `json:"txOrigin,omitempty"`
+}
+
+// GET /transactions/{id}
+type ThorTransaction struct {
+	Id     string    `json:"id"`
+	Origin string    `json:"origin"`
+	Size   int64     `json:"size"`
+	Meta   *ThorMeta `json:"meta"` //pending 状态下为空
+}
+
+// GET /transactions/{id}/receipt
+type ThorReceipt struct {
+	GasUsed  int64     `json:"gasUsed"`
+	GasPayer string    `json:"gasPayer"`
+	Paid     string    `json:"paid"`
+	Reward   string    `json:"reward"`
+	Reverted bool      `json:"reverted"` //交易是否被回滚
+	Meta     *ThorMeta `json:"meta"`
+}
+
+// GET /blocks/{id}
+type ThorBlock struct {
+	Number       int64    `json:"number"`
+	Id           string   `json:"id"`
+	ParentId     string   `json:"parentID"`
+	Timestamp    int64    `json:"timestamp"`
+	IsTrunk      bool     `json:"isTrunk"`
+	Transactions []string `json:"transactions"`
+}
+
+//交易确认结果
+type Confirmation struct {
+	TxId           string
+	BlockId        string
+	BlockNumber    int64
+	BlockTimestamp int64
+	Reverted       bool
+	Depth          int64 //确认深度：最新区块高度 - 交易所在区块高度
+}
+
+type ThorClient struct {
+	NodeUrl string
+	client  *http.Client
+}
+
+func NewThorClient(nodeUrl string) *ThorClient {
+	return &ThorClient{
+		NodeUrl: strings.TrimRight(nodeUrl, "/"),
+		client:  &http.Client{Timeout: 30 * time.Second},
+	}
+}
+
+// 节点对不存在的资源返回 200 + null
+func (self *ThorClient) get(ctx context.Context, path string, out interface{}) (err error) {
+	req, err := http.NewRequest("GET", self.NodeUrl+path, nil)
+	if err != nil {
+		log.Error(err.Error())
+		return
+	}
+	req = req.WithContext(ctx)
+	req.Header.Add("Accept", "application/json")
+
+	resp, err := self.client.Do(req)
+	if err != nil {
+		log.Error(err.Error())
+		return
+	}
+	defer resp.Body.Close()
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		log.Error(err.Error())
+		return
+	}
+	if resp.StatusCode != http.StatusOK {
+		err = fmt.Errorf("ThorNodeStatusError code:%d,body:%s", resp.StatusCode, body)
+		log.Error(err.Error())
+		return
+	}
+	if bytes.Equal(bytes.TrimSpace(body), []byte("null")) {
+		err = ErrThorNotFound
+		return
+	}
+	err = json.Unmarshal(body, out)
+	if err != nil {
+		log.Error(err.Error())
+	}
+	return
+}
+
+func (self *ThorClient) GetTransaction(ctx context.Context, txId string) (tx *ThorTransaction, err error) {
+	tx = new(ThorTransaction)
+	err = self.get(ctx, "/transactions/"+txId, tx)
+	if err != nil {
+		tx = nil
+	}
+	return
+}
+
+func (self *ThorClient) GetReceipt(ctx context.Context, txId string) (receipt *ThorReceipt, err error) {
+	receipt = new(ThorReceipt)
+	err = self.get(ctx, "/transactions/"+txId+"/receipt", receipt)
+	if err != nil {
+		receipt = nil
+	}
+	return
+}
+
+// revision 可以是区块ID、区块高度或 best
+func (self *ThorClient) GetBlock(ctx context.Context, revision string) (block *ThorBlock, err error) {
+	block = new(ThorBlock)
+	err = self.get(ctx, "/blocks/"+revision, block)
+	if err != nil {
+		block = nil
+	}
+	return
+}
+
+// 确认交易是否已打包，返回所在区块及确认深度
+//  交易尚未打包返回 ErrThorPending，节点不认识该交易返回 ErrThorNotFound
+func (self *ThorClient) Confirm(ctx context.Context, txId string) (conf *Confirmation, err error) {
+	receipt, err := self.GetReceipt(ctx, txId)
+	if err == ErrThorNotFound {
+		_, err = self.GetTransaction(ctx, txId)
+		if err == nil {
+			err = ErrThorPending
+		}
+		return
+	}
+	if err != nil {
+		return
+	}
+
+	//确认交易所在区块仍在主链上
+	block, err := self.GetBlock(ctx, receipt.Meta.BlockId)
+	if err == ErrThorNotFound || (err == nil && !block.IsTrunk) {
+		err = ErrThorPending
+		return
+	}
+	if err != nil {
+		return
+	}
+
+	best, err := self.GetBlock(ctx, "best")
+	if err != nil {
+		return
+	}
+
+	conf = new(Confirmation)
+	conf.TxId = txId
+	conf.BlockId = receipt.Meta.BlockId
+	conf.BlockNumber = receipt.Meta.BlockNumber
+	conf.BlockTimestamp = receipt.Meta.BlockTimestamp
+	conf.Reverted = receipt.Reverted
+	conf.Depth = best.Number - receipt.Meta.BlockNumber
+	return
+}
@@ -0,0 +1,58 @@
+package vechain
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func newThorTestServer() *httptest.Server {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/transactions/0x01/receipt", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"gasUsed":21000,"reverted":false,"meta":{"blockID":"0xb1","blockNumber":100,"blockTimestamp":1597111238,"txID":"0x01"}}`))
+	})
+	mux.HandleFunc("/transactions/0x02/receipt", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`null`))
+	})
+	mux.HandleFunc("/transactions/0x02", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"id":"0x02","meta":null}`))
+	})
+	mux.HandleFunc("/transactions/0x03/receipt", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`null`))
+	})
+	mux.HandleFunc("/transactions/0x03", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`null`))
+	})
+	mux.HandleFunc("/blocks/0xb1", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"number":100,"id":"0xb1","isTrunk":true}`))
+	})
+	mux.HandleFunc("/blocks/best", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"number":112,"id":"0xbb","isTrunk":true}`))
+	})
+	return httptest.NewServer(mux)
+}
+
+func TestThorClient_Confirm(t *testing.T) {
+	server := newThorTestServer()
+	defer server.Close()
+	client := NewThorClient(server.URL + "/")
+
+	conf, err := client.Confirm(context.Background(), "0x01")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if conf.BlockNumber != 100 || conf.BlockTimestamp != 1597111238 || conf.Reverted || conf.Depth != 12 {
+		t.Errorf("unexpected confirmation: %+v", *conf)
+	}
+
+	_, err = client.Confirm(context.Background(), "0x02")
+	if err != ErrThorPending {
+		t.Errorf("expect ErrThorPending, got %v", err)
+	}
+
+	_, err = client.Confirm(context.Background(), "0x03")
+	if err != ErrThorNotFound {
+		t.Errorf("expect ErrThorNotFound, got %v", err)
+	}
+}
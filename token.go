@@ -1,21 +1,44 @@
 package vechain
 
 import (
-	"github.com/myafeier/log"
-	"math/rand"
-	"sync/atomic"
+	"context"
+	"sync"
 	"time"
+
+	"github.com/myafeier/log"
+)
+
+const (
+	TokenRefreshAhead     = 1600 * time.Second //token 过期前多久在后台刷新
+	TokenRequestTimeout   = 10 * time.Minute   //一次 token 刷新（含重试）的最长时间
+	TokenRetryMaxInterval = 1 * time.Minute    //token 请求失败重试的最大间隔
 )
 
 type IToken interface {
-	UpdateToken()error
-	GetToken() (token string)
+	UpdateToken(ctx context.Context) error
+	GetToken(ctx context.Context) (token string, err error)
+	//平台返回 token 无效时作废该 token，下次 GetToken 重新申请；token 已被替换时忽略
+	Invalidate(token string)
 }
+
+// DefaultToken 进程内的token管理
+//  并发的调用方共享同一次刷新，token 在过期前 TokenRefreshAhead 自动在后台刷新，凭据轮换后立即刷新
 type DefaultToken struct {
-	config    *VechainConfig
-	token     atomic.Value
-	expire    int64     `json:"expire"`
-	refreshed time.Time `json:"-"` // token更新时间
+	config  *VechainConfig
+	mutex   sync.Mutex
+	token   string
+	expire  time.Time   // token过期时间
+	refresh *tokenCall  // 进行中的刷新
+	timer   *time.Timer // 后台刷新定时器
+	closed  bool
+	stop    chan struct{}
+}
+
+//一次刷新，完成时关闭 done
+type tokenCall struct {
+	done  chan struct{}
+	token string
+	err   error
 }
 
 func init() {
@@ -23,41 +46,142 @@ func init() {
 }
 
 func NewDefaultToken(config *VechainConfig) *DefaultToken {
-	defaultToken := &DefaultToken{config: config}
-	defaultToken.token.Store("")
-	return defaultToken
-}
-
-func (self *DefaultToken) GetToken() string {
-	Retry:
-	old := (self.token.Load()).(string)
-	if old == "" || time.Now().Sub(self.refreshed).Seconds() > float64(self.expire-1600) {
-		err:=self.UpdateToken()
-		if err!=nil{
-			if err==refreshError{
-				rand.Seed(time.Now().Unix())
-				time.Sleep(time.Duration(rand.Intn(100))*time.Microsecond)
-				goto Retry
-			}else{
-				log.Error(err.Error())
-				time.Sleep(10*time.Second)
-				goto Retry
-			}
+	token := &DefaultToken{config: config, stop: make(chan struct{})}
+	go watchCredentials(config.credentials(), token.stop, token.rotate)
+	return token
+}
+
+func (self *DefaultToken) GetToken(ctx context.Context) (token string, err error) {
+	self.mutex.Lock()
+	if self.token != "" && time.Now().Before(self.expire) {
+		token = self.token
+		self.mutex.Unlock()
+		return
+	}
+	call := self.startRefresh()
+	self.mutex.Unlock()
+	return call.wait(ctx)
+}
+
+// 强制刷新token，已有刷新在进行时等待其结果
+func (self *DefaultToken) UpdateToken(ctx context.Context) (err error) {
+	self.mutex.Lock()
+	call := self.startRefresh()
+	self.mutex.Unlock()
+	_, err = call.wait(ctx)
+	return
+}
+
+func (self *DefaultToken) Invalidate(token string) {
+	self.mutex.Lock()
+	defer self.mutex.Unlock()
+	if token != "" && self.token == token {
+		self.token = ""
+	}
+}
+
+// 停止后台刷新
+func (self *DefaultToken) Close() {
+	self.mutex.Lock()
+	defer self.mutex.Unlock()
+	if self.closed {
+		return
+	}
+	self.closed = true
+	close(self.stop)
+	if self.timer != nil {
+		self.timer.Stop()
+	}
+}
+
+//凭据轮换，旧token作废并立即刷新
+func (self *DefaultToken) rotate() {
+	self.mutex.Lock()
+	defer self.mutex.Unlock()
+	self.token = ""
+	if !self.closed {
+		self.startRefresh()
+	}
+}
+
+//凭据每次轮换时调用 rotate，直到 stop 关闭
+func watchCredentials(credentials ICredentials, stop chan struct{}, rotate func()) {
+	for {
+		changed := credentials.Changed()
+		if changed == nil {
+			return
+		}
+		select {
+		case <-changed:
+			log.Info("developer credentials changed, refresh token")
+			rotate()
+		case <-stop:
+			return
 		}
-		return (self.token.Load()).(string)
-	} else {
-		return old
 	}
 }
 
-func (self *DefaultToken) UpdateToken()(err error) {
+//调用方需持有 mutex
+func (self *DefaultToken) startRefresh() *tokenCall {
+	if self.refresh != nil {
+		return self.refresh
+	}
+	call := &tokenCall{done: make(chan struct{})}
+	self.refresh = call
+	go func() {
+		ctx, cancel := context.WithTimeout(context.Background(), TokenRequestTimeout)
+		defer cancel()
+		token, err := RequestToken(ctx, self.config)
 
-	token, err := GetToken(self.config)
-	if err != nil {
+		self.mutex.Lock()
+		if err != nil {
+			log.Error(err.Error())
+			call.err = err
+			self.schedule(TokenRetryMaxInterval)
+		} else {
+			call.token = token.Token
+			self.token = token.Token
+			self.expire = time.Now().Add(time.Duration(token.Expire) * time.Second)
+			self.schedule(refreshAfter(token.Expire))
+		}
+		self.refresh = nil
+		self.mutex.Unlock()
+		close(call.done)
+	}()
+	return call
+}
+
+//调用方需持有 mutex
+func (self *DefaultToken) schedule(after time.Duration) {
+	if self.closed {
 		return
 	}
-	self.token.Store(token.Token)
-	self.refreshed = time.Now()
-	self.expire = token.Expire
-	return
+	if self.timer != nil {
+		self.timer.Stop()
+	}
+	self.timer = time.AfterFunc(after, func() {
+		self.mutex.Lock()
+		if !self.closed {
+			self.startRefresh()
+		}
+		self.mutex.Unlock()
+	})
+}
+
+func (self *tokenCall) wait(ctx context.Context) (token string, err error) {
+	select {
+	case <-self.done:
+		return self.token, self.err
+	case <-ctx.Done():
+		return "", ctx.Err()
+	}
+}
+
+//有效期为 expire 秒的token，多久之后在后台刷新
+func refreshAfter(expire int64) time.Duration {
+	lifetime := time.Duration(expire) * time.Second
+	if lifetime > 2*TokenRefreshAhead {
+		return lifetime - TokenRefreshAhead
+	}
+	return lifetime / 2
 }
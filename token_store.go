@@ -0,0 +1,584 @@
+package vechain
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/myafeier/log"
+	"xorm.io/xorm"
+)
+
+// ============多副本共享token============
+// 平台签发新 token 时会使旧 token 失效，多个副本各自刷新会互相踢掉。
+// 共享存储加租约：只有持有租约的副本向平台申请，其余副本复用存储中的 token
+
+const (
+	TokenLeaseDuration   = 1 * time.Minute        //刷新租约时长，持有者崩溃后租约到期自动释放
+	TokenPollInterval    = 500 * time.Millisecond //等待其他副本刷新时的轮询间隔
+	TokenExpireMargin    = 1 * time.Minute        //距过期不足该时长的 token 视为已过期
+	TokenStoreOfMemory   = "memory"               //仅进程内缓存（默认）
+	TokenStoreOfDatabase = "db"                   //使用 xorm 数据库表 vechain_token
+	TokenStoreOfFile     = "file"                 //使用本地文件，适用于同机多进程
+	tokenFileLeaseSuffix = ".lease"
+	tokenFileLockSuffix  = ".lock"
+	tokenFileLockStale   = 10 * time.Second      //锁文件超过该时长视为持有进程已在临界区内崩溃
+	tokenFileLockPoll    = 10 * time.Millisecond //等待锁文件的轮询间隔
+)
+
+//保存 token 时租约已被其他副本接管
+var ErrLeaseLost = fmt.Errorf("token lease lost")
+
+//存储中的token
+type StoredToken struct {
+	Token  string    `json:"token"`
+	Expire time.Time `json:"expire"` //过期时间
+}
+
+func (self *StoredToken) valid() bool {
+	return self != nil && self.Token != "" && time.Now().Add(TokenExpireMargin).Before(self.Expire)
+}
+
+//token共享存储
+type ITokenStore interface {
+	//读取token，没有记录时返回 nil
+	Load(ctx context.Context) (token *StoredToken, err error)
+	//保存 owner 刷新得到的token
+	Save(ctx context.Context, owner string, token *StoredToken) error
+	//申请刷新租约，其他 owner 持有未到期的租约时返回 false
+	AcquireLease(ctx context.Context, owner string, ttl time.Duration) (ok bool, err error)
+	//释放 owner 持有的租约
+	ReleaseLease(ctx context.Context, owner string) error
+	//存储中的 token 仍为 token 时将其清空
+	Invalidate(ctx context.Context, token string) error
+}
+
+// SharedToken 基于共享存储的token管理
+type SharedToken struct {
+	config *VechainConfig
+	store  ITokenStore
+	owner  string        //本副本的租约标识
+	sem    chan struct{} //进程内同一时间只有一个刷新
+	cached *StoredToken
+	stop   chan struct{}
+	once   sync.Once
+}
+
+func init() {
+	var _ IToken = &SharedToken{}
+	var _ ITokenStore = &DBTokenStore{}
+	var _ ITokenStore = &FileTokenStore{}
+}
+
+func NewSharedToken(config *VechainConfig, store ITokenStore) *SharedToken {
+	hostname, _ := os.Hostname()
+	token := &SharedToken{
+		config: config,
+		store:  store,
+		owner:  fmt.Sprintf("%s-%d-%d", hostname, os.Getpid(), rand.New(rand.NewSource(time.Now().UnixNano())).Int63()),
+		sem:    make(chan struct{}, 1),
+		stop:   make(chan struct{}),
+	}
+	go watchCredentials(config.credentials(), token.stop, token.rotate)
+	return token
+}
+
+// 停止监听凭据轮换
+func (self *SharedToken) Close() {
+	self.once.Do(func() {
+		close(self.stop)
+	})
+}
+
+//凭据轮换，作废旧token后经租约重新申请
+func (self *SharedToken) rotate() {
+	self.sem <- struct{}{}
+	old := self.cached
+	self.cached = nil
+	if old != nil {
+		self.store.Invalidate(context.Background(), old.Token)
+	}
+	self.unlock()
+
+	ctx, cancel := context.WithTimeout(context.Background(), TokenRequestTimeout)
+	defer cancel()
+	_, err := self.GetToken(ctx)
+	if err != nil {
+		log.Error(err.Error())
+	}
+}
+
+func (self *SharedToken) GetToken(ctx context.Context) (token string, err error) {
+	err = self.lock(ctx)
+	if err != nil {
+		return
+	}
+	defer self.unlock()
+
+	if !self.cached.valid() {
+		self.cached, err = self.obtain(ctx, false)
+		if err != nil {
+			return
+		}
+	}
+	token = self.cached.Token
+	return
+}
+
+//同时清空共享存储，避免其他副本继续使用已失效的 token
+func (self *SharedToken) Invalidate(token string) {
+	if token == "" {
+		return
+	}
+	self.sem <- struct{}{}
+	defer self.unlock()
+	if self.cached != nil && self.cached.Token == token {
+		self.cached = nil
+	}
+	self.store.Invalidate(context.Background(), token)
+}
+
+// 强制向平台申请新token并写入共享存储
+func (self *SharedToken) UpdateToken(ctx context.Context) (err error) {
+	err = self.lock(ctx)
+	if err != nil {
+		return
+	}
+	defer self.unlock()
+
+	self.cached, err = self.obtain(ctx, true)
+	return
+}
+
+func (self *SharedToken) lock(ctx context.Context) error {
+	select {
+	case self.sem <- struct{}{}:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func (self *SharedToken) unlock() {
+	<-self.sem
+}
+
+//从共享存储取得有效token，没有时抢租约刷新，抢不到则等待持有者刷新
+func (self *SharedToken) obtain(ctx context.Context, force bool) (token *StoredToken, err error) {
+	for {
+		if !force {
+			token, err = self.store.Load(ctx)
+			if err != nil {
+				return
+			}
+			if token.valid() {
+				return
+			}
+		}
+
+		var ok bool
+		ok, err = self.store.AcquireLease(ctx, self.owner, TokenLeaseDuration)
+		if err != nil {
+			return
+		}
+		if ok {
+			token, err = self.refresh(ctx, force)
+			if err != ErrLeaseLost {
+				return
+			}
+			//刷新期间租约过期并被其他副本接管，改用其他副本保存的 token
+			log.Error("%+v", err.Error())
+			token, err = nil, nil
+		}
+
+		log.Debug("token is refreshing by other replica, wait...")
+		force = false //其他副本正在刷新，等待其结果即可
+		select {
+		case <-ctx.Done():
+			err = ctx.Err()
+			return
+		case <-time.After(TokenPollInterval):
+		}
+	}
+}
+
+//持有租约时刷新
+func (self *SharedToken) refresh(ctx context.Context, force bool) (token *StoredToken, err error) {
+	defer func() {
+		if err != nil {
+			self.store.ReleaseLease(context.Background(), self.owner)
+		}
+	}()
+
+	if !force { //等待租约期间其他副本可能已刷新
+		token, err = self.store.Load(ctx)
+		if err != nil {
+			return
+		}
+		if token.valid() {
+			err = self.store.ReleaseLease(ctx, self.owner)
+			return
+		}
+	}
+
+	leaseCtx, cancel := context.WithTimeout(ctx, TokenLeaseDuration)
+	defer cancel()
+	remote, err := RequestToken(leaseCtx, self.config)
+	if err != nil {
+		return
+	}
+	token = &StoredToken{Token: remote.Token, Expire: time.Now().Add(time.Duration(remote.Expire) * time.Second)}
+	err = self.store.Save(ctx, self.owner, token)
+	if err != nil {
+		token = nil
+	}
+	return
+}
+
+//=====================数据库存储=====================
+
+type TokenModel struct {
+	CommonModel `json:",inline" xorm:"extends"`
+	DeveloperId string    `json:"developer_id" xorm:"varchar(100) default '' unique"` //开发者ID，一个开发者一条记录
+	Token       string    `json:"token" xorm:"varchar(200) default ''"`
+	Expire      time.Time `json:"expire"`
+	LeaseOwner  string    `json:"lease_owner" xorm:"varchar(200) default ''"` //刷新租约持有者
+	LeaseExpire time.Time `json:"lease_expire"`                               //租约到期时间
+}
+
+func (self *TokenModel) TableName() string {
+	return "vechain_token"
+}
+
+// DBTokenStore 使用 vechain_token 表共享token，按当前凭据的 DeveloperId 区分记录，凭据轮换后使用新开发者的记录
+type DBTokenStore struct {
+	engine      *xorm.Engine
+	credentials ICredentials
+}
+
+func NewDBTokenStore(engine *xorm.Engine, credentials ICredentials) *DBTokenStore {
+	return &DBTokenStore{engine: engine, credentials: credentials}
+}
+
+//使用时读取当前凭据的 DeveloperId
+func (self *DBTokenStore) developerId(ctx context.Context) (developerId string, err error) {
+	c, err := self.credentials.Get(ctx)
+	if err != nil {
+		log.Error("%+v", err.Error())
+		return
+	}
+	developerId = c.DeveloperId
+	return
+}
+
+func (self *DBTokenStore) Load(ctx context.Context) (token *StoredToken, err error) {
+	developerId, err := self.developerId(ctx)
+	if err != nil {
+		return
+	}
+	m := new(TokenModel)
+	has, err := self.engine.Context(ctx).Where("developer_id=?", developerId).Get(m)
+	if err != nil {
+		log.Error("%+v", err.Error())
+		return
+	}
+	if has {
+		token = &StoredToken{Token: m.Token, Expire: m.Expire}
+	}
+	return
+}
+
+func (self *DBTokenStore) Save(ctx context.Context, owner string, token *StoredToken) (err error) {
+	developerId, err := self.developerId(ctx)
+	if err != nil {
+		return
+	}
+	m := &TokenModel{Token: token.Token, Expire: token.Expire}
+	n, err := self.engine.Context(ctx).Where("developer_id=?", developerId).And("lease_owner=?", owner).Cols("token", "expire", "lease_owner").Update(m)
+	if err != nil {
+		log.Error("%+v", err.Error())
+		return
+	}
+	if n == 0 { //租约已被其他副本接管，不能使用本副本申请的 token
+		err = ErrLeaseLost
+	}
+	return
+}
+
+func (self *DBTokenStore) AcquireLease(ctx context.Context, owner string, ttl time.Duration) (ok bool, err error) {
+	developerId, err := self.developerId(ctx)
+	if err != nil {
+		return
+	}
+	session := self.engine.Context(ctx)
+	defer session.Close()
+
+	has, err := session.Where("developer_id=?", developerId).Exist(&TokenModel{})
+	if err != nil {
+		log.Error("%+v", err.Error())
+		return
+	}
+	if !has {
+		_, err = session.Insert(&TokenModel{DeveloperId: developerId})
+		if err != nil {
+			//并发插入时唯一索引冲突，记录已由其他副本插入则继续抢占
+			has, _ = session.Where("developer_id=?", developerId).Exist(&TokenModel{})
+			if !has {
+				log.Error("%+v", err.Error())
+				return
+			}
+			err = nil
+		}
+	}
+
+	now := time.Now()
+	m := &TokenModel{LeaseOwner: owner, LeaseExpire: now.Add(ttl)}
+	_, err = session.Where("developer_id=?", developerId).And("(lease_owner='' OR lease_owner=? OR lease_expire<?)", owner, now).Cols("lease_owner", "lease_expire").Update(m)
+	if err != nil {
+		log.Error("%+v", err.Error())
+		return
+	}
+	//值未变化时部分数据库返回的影响行数为 0，以更新后的持有者为准
+	m = new(TokenModel)
+	_, err = session.Where("developer_id=?", developerId).Cols("lease_owner").Get(m)
+	if err != nil {
+		log.Error("%+v", err.Error())
+		return
+	}
+	ok = m.LeaseOwner == owner
+	return
+}
+
+func (self *DBTokenStore) ReleaseLease(ctx context.Context, owner string) (err error) {
+	developerId, err := self.developerId(ctx)
+	if err != nil {
+		return
+	}
+	_, err = self.engine.Context(ctx).Where("developer_id=?", developerId).And("lease_owner=?", owner).Cols("lease_owner").Update(&TokenModel{})
+	if err != nil {
+		log.Error("%+v", err.Error())
+	}
+	return
+}
+
+func (self *DBTokenStore) Invalidate(ctx context.Context, token string) (err error) {
+	developerId, err := self.developerId(ctx)
+	if err != nil {
+		return
+	}
+	_, err = self.engine.Context(ctx).Where("developer_id=?", developerId).And("token=?", token).Cols("token").Update(&TokenModel{})
+	if err != nil {
+		log.Error("%+v", err.Error())
+	}
+	return
+}
+
+//=====================文件存储=====================
+
+// FileTokenStore 使用本地 JSON 文件共享token，租约为同目录下的 .lease 文件
+//  租约和 token 的读改写都在以 O_EXCL 创建的 .lock 文件保护下进行，多个进程同时接管时只有一个成功
+type FileTokenStore struct {
+	path string
+}
+
+func NewFileTokenStore(path string) *FileTokenStore {
+	return &FileTokenStore{path: path}
+}
+
+//租约文件内容
+type fileLease struct {
+	Owner  string    `json:"owner"`
+	Expire time.Time `json:"expire"`
+}
+
+func (self *FileTokenStore) Load(ctx context.Context) (token *StoredToken, err error) {
+	data, err := ioutil.ReadFile(self.path)
+	if os.IsNotExist(err) {
+		err = nil
+		return
+	}
+	if err != nil {
+		log.Error("%+v", err.Error())
+		return
+	}
+	token = new(StoredToken)
+	err = json.Unmarshal(data, token)
+	if err != nil {
+		log.Error("%+v", err.Error())
+		token = nil
+	}
+	return
+}
+
+func (self *FileTokenStore) Save(ctx context.Context, owner string, token *StoredToken) (err error) {
+	unlock, err := self.lock(ctx)
+	if err != nil {
+		return
+	}
+	defer unlock()
+	lease, err := self.readLease()
+	if err != nil {
+		return
+	}
+	if lease == nil || lease.Owner != owner {
+		err = ErrLeaseLost
+		return
+	}
+
+	err = self.write(token)
+	if err != nil {
+		return
+	}
+	return self.removeLease()
+}
+
+func (self *FileTokenStore) Invalidate(ctx context.Context, token string) (err error) {
+	unlock, err := self.lock(ctx)
+	if err != nil {
+		return
+	}
+	defer unlock()
+	stored, err := self.Load(ctx)
+	if err != nil || stored == nil || stored.Token != token {
+		return
+	}
+	return self.write(&StoredToken{})
+}
+
+//以 O_EXCL 创建锁文件作为跨进程互斥锁，返回释放锁的函数
+func (self *FileTokenStore) lock(ctx context.Context) (unlock func(), err error) {
+	path := self.path + tokenFileLockSuffix
+	for {
+		var file *os.File
+		file, err = os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0600)
+		if err == nil {
+			file.Close()
+			unlock = func() { os.Remove(path) }
+			return
+		}
+		if !os.IsExist(err) {
+			log.Error("%+v", err.Error())
+			return
+		}
+		//临界区只有几次文件读写，锁文件长时间存在说明持有进程已崩溃
+		if info, statErr := os.Stat(path); statErr == nil && time.Since(info.ModTime()) > tokenFileLockStale {
+			os.Remove(path)
+			continue
+		}
+		select {
+		case <-ctx.Done():
+			err = ctx.Err()
+			return
+		case <-time.After(tokenFileLockPoll):
+		}
+	}
+}
+
+//写临时文件后改名，读取方不会看到写了一半的内容
+func (self *FileTokenStore) write(token *StoredToken) (err error) {
+	data, err := json.Marshal(token)
+	if err != nil {
+		return
+	}
+	return writeFileAtomic(self.path, data)
+}
+
+//先写临时文件再改名，读取方不会读到写了一半的文件
+func writeFileAtomic(path string, data []byte) (err error) {
+	tmp, err := ioutil.TempFile(filepath.Dir(path), filepath.Base(path)+".tmp")
+	if err != nil {
+		log.Error("%+v", err.Error())
+		return
+	}
+	_, err = tmp.Write(data)
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Rename(tmp.Name(), path)
+	}
+	if err != nil {
+		os.Remove(tmp.Name())
+		log.Error("%+v", err.Error())
+	}
+	return
+}
+
+func (self *FileTokenStore) AcquireLease(ctx context.Context, owner string, ttl time.Duration) (ok bool, err error) {
+	unlock, err := self.lock(ctx)
+	if err != nil {
+		return
+	}
+	defer unlock()
+	lease, err := self.readLease()
+	if err != nil {
+		return
+	}
+	//其他副本持有未到期的租约；本副本续期或租约已过期（持有者可能已崩溃）时接管
+	if lease != nil && lease.Owner != owner && time.Now().Before(lease.Expire) {
+		return
+	}
+	err = self.writeLease(&fileLease{Owner: owner, Expire: time.Now().Add(ttl)})
+	if err != nil {
+		return
+	}
+	ok = true
+	return
+}
+
+func (self *FileTokenStore) writeLease(lease *fileLease) (err error) {
+	data, err := json.Marshal(lease)
+	if err != nil {
+		return
+	}
+	return writeFileAtomic(self.path+tokenFileLeaseSuffix, data)
+}
+
+func (self *FileTokenStore) ReleaseLease(ctx context.Context, owner string) (err error) {
+	unlock, err := self.lock(ctx)
+	if err != nil {
+		return
+	}
+	defer unlock()
+	lease, err := self.readLease()
+	if err != nil || lease == nil || lease.Owner != owner {
+		return
+	}
+	return self.removeLease()
+}
+
+func (self *FileTokenStore) removeLease() (err error) {
+	err = os.Remove(self.path + tokenFileLeaseSuffix)
+	if os.IsNotExist(err) {
+		err = nil
+	}
+	return
+}
+
+//读取租约文件，不存在时返回 nil；内容不完整时按文件修改时间计算租约到期
+func (self *FileTokenStore) readLease() (lease *fileLease, err error) {
+	data, err := ioutil.ReadFile(self.path + tokenFileLeaseSuffix)
+	if os.IsNotExist(err) {
+		err = nil
+		return
+	}
+	if err != nil {
+		log.Error("%+v", err.Error())
+		return
+	}
+	lease = new(fileLease)
+	if len(strings.TrimSpace(string(data))) == 0 || json.Unmarshal(data, lease) != nil {
+		lease = &fileLease{Expire: time.Now().Add(TokenLeaseDuration)}
+		if info, statErr := os.Stat(self.path + tokenFileLeaseSuffix); statErr == nil {
+			lease.Expire = info.ModTime().Add(TokenLeaseDuration)
+		}
+	}
+	return
+}
@@ -0,0 +1,216 @@
+package vechain
+
+import (
+	"context"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestSharedToken_FileStore(t *testing.T) {
+	var requests int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&requests, 1)
+		time.Sleep(50 * time.Millisecond)
+		w.Write([]byte(`{"code":1,"data":{"token":"T","expire":7200}}`))
+	}))
+	defer server.Close()
+
+	dir, err := ioutil.TempDir("", "vechain-token")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "token.json")
+	cfg := &VechainConfig{SiteUrl: server.URL + "/"}
+
+	//模拟多个副本
+	var wg sync.WaitGroup
+	for i := 0; i < 5; i++ {
+		replica := NewSharedToken(cfg, NewFileTokenStore(path))
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			tokenStr, err := replica.GetToken(context.Background())
+			if err != nil || tokenStr != "T" {
+				t.Errorf("get token: %q %v", tokenStr, err)
+			}
+		}()
+	}
+	wg.Wait()
+	if n := atomic.LoadInt32(&requests); n != 1 {
+		t.Errorf("expect 1 token request, got %d", n)
+	}
+	if _, err = os.Stat(path + tokenFileLeaseSuffix); !os.IsNotExist(err) {
+		t.Errorf("lease not released: %v", err)
+	}
+}
+
+func TestFileTokenStore_ExpiredLease(t *testing.T) {
+	dir, err := ioutil.TempDir("", "vechain-token")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	store := NewFileTokenStore(filepath.Join(dir, "token.json"))
+	ctx := context.Background()
+
+	ok, err := store.AcquireLease(ctx, "a", -time.Second)
+	if err != nil || !ok {
+		t.Fatalf("acquire lease: %v %v", ok, err)
+	}
+	ok, err = store.AcquireLease(ctx, "b", time.Minute)
+	if err != nil || !ok {
+		t.Fatalf("expired lease should be taken over: %v %v", ok, err)
+	}
+	ok, err = store.AcquireLease(ctx, "a", time.Minute)
+	if err != nil || ok {
+		t.Fatalf("lease held by b: %v %v", ok, err)
+	}
+}
+
+func TestFileTokenStore_RenewLease(t *testing.T) {
+	dir, err := ioutil.TempDir("", "vechain-token")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	store := NewFileTokenStore(filepath.Join(dir, "token.json"))
+	ctx := context.Background()
+
+	if ok, err := store.AcquireLease(ctx, "a", time.Second); err != nil || !ok {
+		t.Fatalf("acquire lease: %v %v", ok, err)
+	}
+	if ok, err := store.AcquireLease(ctx, "a", time.Hour); err != nil || !ok {
+		t.Fatalf("renew lease: %v %v", ok, err)
+	}
+	lease, err := store.readLease()
+	if err != nil || lease.Owner != "a" || time.Until(lease.Expire) < 50*time.Minute {
+		t.Errorf("lease not extended: %+v %v", lease, err)
+	}
+}
+
+func TestDBTokenStore_AcquireLease(t *testing.T) {
+	s, cleanup := newTestService(t, &VechainConfig{})
+	defer cleanup()
+	store := NewDBTokenStore(s.dbEngine, &StaticCredentials{DeveloperId: "D1"})
+	ctx := context.Background()
+
+	if ok, err := store.AcquireLease(ctx, "a", time.Minute); err != nil || !ok {
+		t.Fatalf("acquire lease: %v %v", ok, err)
+	}
+	//同一持有者在同一时刻重复申请，影响行数可能为 0
+	if ok, err := store.AcquireLease(ctx, "a", time.Minute); err != nil || !ok {
+		t.Fatalf("renew lease: %v %v", ok, err)
+	}
+	if ok, err := store.AcquireLease(ctx, "b", time.Minute); err != nil || ok {
+		t.Fatalf("lease held by a: %v %v", ok, err)
+	}
+	if err := store.ReleaseLease(ctx, "a"); err != nil {
+		t.Fatal(err)
+	}
+	if ok, err := store.AcquireLease(ctx, "b", -time.Second); err != nil || !ok {
+		t.Fatalf("released lease: %v %v", ok, err)
+	}
+	if ok, err := store.AcquireLease(ctx, "a", time.Minute); err != nil || !ok {
+		t.Fatalf("expired lease should be taken over: %v %v", ok, err)
+	}
+}
+
+func TestDBTokenStore_RotateCredentials(t *testing.T) {
+	s, cleanup := newTestService(t, &VechainConfig{})
+	defer cleanup()
+	credentials := NewMemoryCredentials(Credentials{DeveloperId: "D1", DeveloperKey: "K1"})
+	store := NewDBTokenStore(s.dbEngine, credentials)
+	ctx := context.Background()
+
+	if ok, err := store.AcquireLease(ctx, "a", time.Minute); err != nil || !ok {
+		t.Fatalf("acquire lease: %v %v", ok, err)
+	}
+	if err := store.Save(ctx, "a", &StoredToken{Token: "T1", Expire: time.Now().Add(time.Hour)}); err != nil {
+		t.Fatal(err)
+	}
+
+	//轮换后使用新开发者的记录，不会读到旧开发者的 token
+	credentials.Rotate(Credentials{DeveloperId: "D2", DeveloperKey: "K2"})
+	token, err := store.Load(ctx)
+	if err != nil || token != nil {
+		t.Errorf("expect no token for D2, got %+v %v", token, err)
+	}
+	if ok, err := store.AcquireLease(ctx, "b", time.Minute); err != nil || !ok {
+		t.Errorf("lease of D1 should not block D2: %v %v", ok, err)
+	}
+}
+
+func TestFileTokenStore_ConcurrentTakeover(t *testing.T) {
+	dir, err := ioutil.TempDir("", "vechain-token")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "token.json")
+	ctx := context.Background()
+	if ok, err := NewFileTokenStore(path).AcquireLease(ctx, "crashed", -time.Second); err != nil || !ok {
+		t.Fatalf("acquire lease: %v %v", ok, err)
+	}
+
+	//多个副本同时接管已过期的租约，只有一个成功
+	var wg sync.WaitGroup
+	var owners int32
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func(owner string) {
+			defer wg.Done()
+			ok, err := NewFileTokenStore(path).AcquireLease(ctx, owner, time.Minute)
+			if err != nil {
+				t.Error(err)
+			}
+			if ok {
+				atomic.AddInt32(&owners, 1)
+			}
+		}(string(rune('a' + i)))
+	}
+	wg.Wait()
+	if owners != 1 {
+		t.Errorf("expect exactly one owner, got %d", owners)
+	}
+}
+
+func TestTokenStore_SaveLeaseLost(t *testing.T) {
+	dir, err := ioutil.TempDir("", "vechain-token")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	s, cleanup := newTestService(t, &VechainConfig{})
+	defer cleanup()
+	ctx := context.Background()
+
+	for name, store := range map[string]ITokenStore{
+		"file": NewFileTokenStore(filepath.Join(dir, "token.json")),
+		"db":   NewDBTokenStore(s.dbEngine, &StaticCredentials{DeveloperId: "D1"}),
+	} {
+		//a 的租约过期后被 b 接管，a 保存时发现租约已失去
+		if ok, err := store.AcquireLease(ctx, "a", -time.Second); err != nil || !ok {
+			t.Fatalf("%s: acquire lease: %v %v", name, ok, err)
+		}
+		if ok, err := store.AcquireLease(ctx, "b", time.Minute); err != nil || !ok {
+			t.Fatalf("%s: take over lease: %v %v", name, ok, err)
+		}
+		if err := store.Save(ctx, "a", &StoredToken{Token: "TA", Expire: time.Now().Add(time.Hour)}); err != ErrLeaseLost {
+			t.Errorf("%s: expect ErrLeaseLost, got %v", name, err)
+		}
+		if err := store.Save(ctx, "b", &StoredToken{Token: "TB", Expire: time.Now().Add(time.Hour)}); err != nil {
+			t.Errorf("%s: save by lease owner: %v", name, err)
+		}
+		if token, err := store.Load(ctx); err != nil || token == nil || token.Token != "TB" {
+			t.Errorf("%s: expect TB, got %+v %v", name, token, err)
+		}
+	}
+}
@@ -1,11 +1,67 @@
 package vechain
 
-import "testing"
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
 
 func TestDefaultToken_GetToken(t *testing.T) {
-	token:=NewDefaultToken(config)
-	tokenStr:=token.GetToken()
-	if tokenStr==""{
+	requireIntegration(t)
+	token := NewDefaultToken(config)
+	defer token.Close()
+	tokenStr, err := token.GetToken(context.Background())
+	if err != nil || tokenStr == "" {
 		t.Error("get token error")
 	}
 }
+
+func TestDefaultToken_SingleFlight(t *testing.T) {
+	var requests int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&requests, 1)
+		time.Sleep(50 * time.Millisecond)
+		w.Write([]byte(`{"code":1,"data":{"token":"T","expire":7200}}`))
+	}))
+	defer server.Close()
+
+	token := NewDefaultToken(&VechainConfig{SiteUrl: server.URL + "/"})
+	defer token.Close()
+
+	var wg sync.WaitGroup
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			tokenStr, err := token.GetToken(context.Background())
+			if err != nil || tokenStr != "T" {
+				t.Errorf("get token: %q %v", tokenStr, err)
+			}
+		}()
+	}
+	wg.Wait()
+	if n := atomic.LoadInt32(&requests); n != 1 {
+		t.Errorf("expect 1 token request, got %d", n)
+	}
+}
+
+func TestDefaultToken_ContextCanceled(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer server.Close()
+
+	token := NewDefaultToken(&VechainConfig{SiteUrl: server.URL + "/"})
+	defer token.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	_, err := token.GetToken(ctx)
+	if err != context.DeadlineExceeded {
+		t.Errorf("expect DeadlineExceeded, got %v", err)
+	}
+}
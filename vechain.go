@@ -6,11 +6,12 @@ import (
 	"crypto/sha256"
 	"encoding/json"
 	"fmt"
+	"io"
 	"io/ioutil"
 	"net/http"
+	"net/url"
 	"strconv"
 	"strings"
-	"sync/atomic"
 	"time"
 
 	"github.com/myafeier/log"
@@ -34,12 +35,114 @@ type ResponseData struct {
 	Message string      `json:"message"`
 }
 
-func sign(timestamp int64, config *VechainConfig) (signature string) {
-	str := fmt.Sprintf("appid=%s&appkey=%s&nonce=%s&timestamp=%d", config.DeveloperId, config.DeveloperKey, config.Nonce, timestamp)
+func sign(timestamp int64, nonce string, credentials *Credentials) (signature string) {
+	str := fmt.Sprintf("appid=%s&appkey=%s&nonce=%s&timestamp=%d", credentials.DeveloperId, credentials.DeveloperKey, nonce, timestamp)
 	signature = fmt.Sprintf("%x", sha256.Sum256([]byte(strings.ToLower(str))))
 	return
 }
 
+//接口返回码不为 1 时的错误
+type ApiError struct {
+	Api     string
+	Code    int
+	Message string
+}
+
+func (self *ApiError) Error() string {
+	return fmt.Sprintf("%s error, remote response Code:%d, MSG: %s.", self.Api, self.Code, self.Message)
+}
+
+const (
+	ResponseCodeTokenInvalid = 100004 //token 无效或已被新 token 顶替
+	MaxReauthAttempts        = 3      //一次请求因 token 无效重新认证的最大次数
+)
+
+var ErrTooManyReauth = fmt.Errorf("token still invalid after %d re-auth attempts", MaxReauthAttempts)
+
+// 发起接口请求，data 为 ResponseData.Data 的解析目标
+//  body 为空时发送 GET 请求，query 附加在地址之后；token 无效时作废并重新认证，最多 MaxReauthAttempts 次
+func callApi(ctx context.Context, config *VechainConfig, tokenServer IToken, api string, query url.Values, body interface{}, data interface{}) (err error) {
+	var payload []byte
+	if body != nil {
+		payload, err = json.Marshal(body)
+		if err != nil {
+			log.Error(err.Error())
+			return
+		}
+	}
+	for reauthTimes := 0; ; reauthTimes++ {
+		var token string
+		token, err = callApiOnce(ctx, config, tokenServer, api, query, payload, data)
+		apiErr, ok := err.(*ApiError)
+		if !ok || apiErr.Code != ResponseCodeTokenInvalid {
+			return
+		}
+		tokenServer.Invalidate(token)
+		if reauthTimes >= MaxReauthAttempts {
+			err = ErrTooManyReauth
+			log.Error(err.Error())
+			return
+		}
+	}
+}
+
+func callApiOnce(ctx context.Context, config *VechainConfig, tokenServer IToken, api string, query url.Values, payload []byte, data interface{}) (token string, err error) {
+	method := "GET"
+	var reader io.Reader
+	if payload != nil {
+		method = "POST"
+		reader = bytes.NewReader(payload)
+	}
+	requestUrl := config.SiteUrl + api
+	if len(query) > 0 {
+		requestUrl += "?" + query.Encode()
+	}
+	req, err := http.NewRequest(method, requestUrl, reader)
+	if err != nil {
+		log.Error(err.Error())
+		return
+	}
+	req = req.WithContext(ctx)
+	req.Header.Add("Content-Type", "application/json;charset=utf-8")
+	req.Header.Add("language", "zh_hans")
+	token, err = tokenServer.GetToken(ctx)
+	if err != nil {
+		log.Error(err.Error())
+		return
+	}
+	req.Header.Add("x-api-token", token)
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		log.Error(err.Error())
+		return
+	}
+	defer resp.Body.Close()
+	respBody, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		log.Error(err.Error())
+		return
+	}
+	if resp.StatusCode != http.StatusOK {
+		err = fmt.Errorf("RemoteServerStatusError code:%d,body:%s", resp.StatusCode, respBody)
+		log.Error(err.Error())
+		return
+	}
+
+	respData := new(ResponseData)
+	respData.Data = data
+	err = json.Unmarshal(respBody, respData)
+	if err != nil {
+		log.Error(err.Error())
+		return
+	}
+	if respData.Code != 1 {
+		err = &ApiError{Api: api, Code: respData.Code, Message: respData.Message}
+		log.Error(err.Error())
+	}
+	return
+}
+
 //区块链浏览器浏览地址
 func BlockChainExploreLink(transactionId string, config *VechainConfig) string {
 	return fmt.Sprintf(config.ExploreLink, transactionId)
@@ -52,76 +155,80 @@ type Token struct {
 	Expire int64  `json:"expire"`
 }
 
-var lock int32 = 0
-var refreshError = fmt.Errorf("token refreshing")
+// 向平台申请新的token，失败时按递增间隔重试，直到成功或 ctx 结束
+//  每次请求都重新读取凭据并生成新的 nonce
+func RequestToken(ctx context.Context, config *VechainConfig) (token *Token, err error) {
+	requestUrl := config.SiteUrl + "v1/tokens"
+	retryTimes := 0
+
+	for {
+		token, err = requestToken(ctx, requestUrl, config.credentials())
+		if err == nil {
+			return
+		}
+		log.Error(err.Error())
 
-func GetToken(config *VechainConfig) (token *Token, err error) {
-	if atomic.LoadInt32(&lock) == 1 {
-		err = refreshError
-		return
+		retryTimes++
+		wait := time.Duration(retryTimes) * time.Second
+		if wait > TokenRetryMaxInterval {
+			wait = TokenRetryMaxInterval
+		}
+		select {
+		case <-ctx.Done():
+			err = ctx.Err()
+			return
+		case <-time.After(wait):
+		}
 	}
-	atomic.StoreInt32(&lock, 1)
-	defer atomic.StoreInt32(&lock, 0)
+}
 
+func requestToken(ctx context.Context, requestUrl string, provider ICredentials) (token *Token, err error) {
+	credentials, err := provider.Get(ctx)
+	if err != nil {
+		return
+	}
 	timestamp := time.Now().Unix()
 	form := new(Form)
-	form.AppId = config.DeveloperId
-	form.AppKey = config.DeveloperKey
-	form.Nonce = config.Nonce
+	form.AppId = credentials.DeveloperId
+	form.AppKey = credentials.DeveloperKey
+	form.Nonce = Nonce()
 	form.Timestamp = strconv.FormatInt(timestamp, 10)
-	form.Signature = sign(timestamp, config)
-
-	requestUrl := config.SiteUrl + "v1/tokens"
+	form.Signature = sign(timestamp, form.Nonce, credentials)
 	formByte, err := json.Marshal(form)
 	if err != nil {
-		log.Error("%s", err.Error())
 		return
 	}
-	log.Debug("%+v", *form)
-	data := bytes.NewReader(formByte)
-	retryTimes := 0
 
-Retry:
-	retryTimes++
-	if retryTimes > 100 {
-		time.Sleep(1 * time.Minute)
-	} else if retryTimes > 1000 {
-		time.Sleep(1 * time.Hour)
-	}
-	request, err := http.NewRequest("POST", requestUrl, data)
+	request, err := http.NewRequest("POST", requestUrl, bytes.NewReader(formByte))
 	if err != nil {
-		log.Error("%s", err.Error())
-		goto Retry
+		return
 	}
-	defer request.Body.Close()
-
+	request = request.WithContext(ctx)
 	request.Header.Set("Content-Type", "application/json")
 
-	client := &http.Client{}
-	response, err := client.Do(request)
+	response, err := http.DefaultClient.Do(request)
 	if err != nil {
-		log.Error("%s", err.Error())
-		goto Retry
+		return
 	}
 	defer response.Body.Close()
-	if response.StatusCode != 200 {
-		log.Error("%s", err.Error())
-		goto Retry
-	}
-
 	body, err := ioutil.ReadAll(response.Body)
 	if err != nil {
-		log.Error("%s", err.Error())
-		goto Retry
+		return
+	}
+	if response.StatusCode != 200 {
+		err = fmt.Errorf("RemoteServerStatusError code:%d,body:%s", response.StatusCode, body)
+		return
 	}
 	log.Debug("toke response :%s \n", body)
 	respData := new(ResponseData)
 	respData.Data = new(Token)
 	err = json.Unmarshal(body, respData)
+	if err != nil {
+		return
+	}
 	if respData.Code != 1 {
-		err = fmt.Errorf("responseCode:%d error,message:%s\n", respData.Code, respData.Message)
-		log.Error(err.Error())
-		goto Retry
+		err = &ApiError{Api: "v1/tokens", Code: respData.Code, Message: respData.Message}
+		return
 	}
 	token = respData.Data.(*Token)
 	return
@@ -164,9 +271,14 @@ func OccupyVid(ctx context.Context, config *VechainConfig, tokenServer IToken) (
 	}()
 
 	retryTimes := 0
+	reauthTimes := 0
 
 RetryWithNewToken:
-	token := tokenServer.GetToken()
+	token, err := tokenServer.GetToken(ctx)
+	if err != nil {
+		log.Error(err.Error())
+		return
+	}
 Retry:
 	retryTimes++
 
@@ -225,7 +337,14 @@ Retry:
 			goto Retry
 		}
 		return
-	} else if respData.Code == 100004 {
+	} else if respData.Code == ResponseCodeTokenInvalid {
+		tokenServer.Invalidate(token)
+		reauthTimes++
+		if reauthTimes > MaxReauthAttempts {
+			err = ErrTooManyReauth
+			log.Error(err.Error())
+			return
+		}
 		goto RetryWithNewToken
 	} else {
 		err = fmt.Errorf("Occupy vid error, remote response Code:%d, MSG: %s.", respData.Code, respData.Message)
@@ -234,13 +353,25 @@ Retry:
 	return
 }
 
+// 发送一次抢占请求，不轮询，返回该 requestNo 当前的结果
+//  平台按 requestNo 识别同一请求，重发原报文返回原请求的结果（OccupyVid 轮询 GENERATING 也是如此），
+//  平台还没有该 requestNo 时即为首次提交
+func OccupyVidOnce(ctx context.Context, config *VechainConfig, tokenServer IToken, request *OccupyVidRequest) (response *OccupyVidResponse, err error) {
+	response = new(OccupyVidResponse)
+	err = callApi(ctx, config, tokenServer, "v1/vid/occupy", nil, request, response)
+	if err != nil {
+		response = nil
+	}
+	return
+}
+
 //================================Post========
 
 type PostArtifactResponse struct {
 	RequestNo string                      `json:"requestNo,omitempty"` // 请求编号
 	Uid       string                      `json:"uid,omitempty"`       // 上链子账户id
 	Status    string                      `json:"status,omitempty"`    // 生成状态(PROCESSING:上链中，SUCCESS：成功，FAILURE： 失败,INSUFFICIENT:费用不足)
-	TxList    []*PostArtifactResponseData `json:"txList，omitempty"`    //上链结果
+	TxList    []*PostArtifactResponseData `json:"txList,omitempty"`    //上链结果
 }
 type PostArtifactResponseData struct {
 	TxId        string `json:"txid"`        //上链事务id
@@ -283,8 +414,13 @@ func PostArtifact(ctx context.Context, config *VechainConfig, tokenServer IToken
 	}()
 
 	retryTimes := 0
+	reauthTimes := 0
 RetryWithNewToken:
-	token := tokenServer.GetToken()
+	token, err := tokenServer.GetToken(ctx)
+	if err != nil {
+		log.Error(err.Error())
+		return
+	}
 Retry:
 	retryTimes++
 
@@ -345,7 +481,14 @@ Retry:
 			time.Sleep(1 * time.Minute)
 			goto Retry
 		}
-	} else if respData.Code == 100004 {
+	} else if respData.Code == ResponseCodeTokenInvalid {
+		tokenServer.Invalidate(token)
+		reauthTimes++
+		if reauthTimes > MaxReauthAttempts {
+			err = ErrTooManyReauth
+			log.Error(err.Error())
+			return
+		}
 		goto RetryWithNewToken
 	} else {
 		err = fmt.Errorf("PostArtifactResponseerror, remote response Code:%d, MSG: %s.", respData.Code, respData.Message)
@@ -355,6 +498,16 @@ Retry:
 	return
 }
 
+// 发送一次上链请求，不轮询，返回该 requestNo 当前的结果，说明同 OccupyVidOnce
+func PostArtifactOnce(ctx context.Context, config *VechainConfig, tokenServer IToken, request *PostArtifactRequest) (response *PostArtifactResponse, err error) {
+	response = new(PostArtifactResponse)
+	err = callApi(ctx, config, tokenServer, "v1/artifacts/hashinfo/create", nil, request, response)
+	if err != nil {
+		response = nil
+	}
+	return
+}
+
 //================CreateAccount============
 type CreateUser struct {
 	RequestNo string `json:"requestNo"` //请求编号
@@ -362,54 +515,66 @@ type CreateUser struct {
 	Status    string `json:"status"`    //状态（PROCESSING:处理中，SUCCESS：成功，FAILURE：失败）
 }
 
-// 创建账号
-//  在此系统只只需创建一个账号，无多账户的需求。
-func GenerateSubAccount(requestNo, accountName string, config *VechainConfig, tokenServer IToken) (uid string, err error) {
-	url := "v1/artifacts/user/create"
-	postData := `
-		{
-			"requestNo":"%s",
-			"name":"%s"
-		}
-	`
+//创建子账户请求
+type CreateUserRequest struct {
+	RequestNo string `json:"requestNo"` //请求编号，重复提交同一编号时返回原请求的结果
+	Name      string `json:"name"`      //账户名
+}
 
-	req, err := http.NewRequest("POST", config.SiteUrl+url, bytes.NewBufferString(fmt.Sprintf(postData, requestNo, accountName)))
+// 创建子账户
+//  平台异步处理，返回时 Status 可能为 PROCESSING；wait 为 true 时轮询直到 SUCCESS 或 FAILURE，FAILURE 时返回错误
+func GenerateSubAccount(ctx context.Context, config *VechainConfig, tokenServer IToken, requestNo, accountName string, wait bool) (user *CreateUser, err error) {
+	request := &CreateUserRequest{RequestNo: requestNo, Name: accountName}
+	user = new(CreateUser)
+	err = callApi(ctx, config, tokenServer, "v1/artifacts/user/create", nil, request, user)
 	if err != nil {
-		log.Error(err.Error())
+		user = nil
 		return
 	}
-	req.Header.Add("Content-Type", "application/json;charset=utf-8")
-	req.Header.Add("language", "zh_hans")
-	req.Header.Add("x-api-token", tokenServer.GetToken())
-
-	client := http.DefaultClient
-
-	resp, err := client.Do(req)
-	if err != nil {
-		log.Error(err.Error())
-		return
+	if user.RequestNo == "" {
+		user.RequestNo = requestNo
 	}
-	defer resp.Body.Close()
-
-	respBody, err := ioutil.ReadAll(resp.Body)
-	if err != nil {
-		log.Error(err.Error())
+	log.Debug("create user: %+v", *user)
+	if !wait {
 		return
 	}
-
-	respData := new(ResponseData)
-	respData.Data = new(CreateUser)
-
-	err = json.Unmarshal(respBody, respData)
-	if err != nil {
-		log.Error(err.Error())
-		return
+	if !user.Finished() {
+		user, err = WaitCreateUser(ctx, config, tokenServer, requestNo, accountName)
+		if err != nil {
+			return
+		}
 	}
-
-	if respData.Code == 1 {
-		log.Debug("response %d,%s,%+v \n", resp.StatusCode, resp.Status, *(respData.Data.(*CreateUser)))
+	if user.Status != AccountStateSuccess {
+		err = fmt.Errorf("create sub account failed, requestNo:%s status:%s", requestNo, user.Status)
+		log.Error(err.Error())
 	}
-	uid = respData.Data.(*CreateUser).Uid
 	return
+}
+
+//平台已处理完成（成功或失败）
+func (self *CreateUser) Finished() bool {
+	return self.Status == AccountStateSuccess || self.Status == AccountStateFailure
+}
 
+// 按请求编号轮询子账户创建结果，直到 SUCCESS 或 FAILURE 或 ctx 结束
+//  平台按 requestNo 识别同一请求，用原请求编号和账户名重发创建请求即返回原请求的结果，不会重复创建
+func WaitCreateUser(ctx context.Context, config *VechainConfig, tokenServer IToken, requestNo, accountName string) (user *CreateUser, err error) {
+	request := &CreateUserRequest{RequestNo: requestNo, Name: accountName}
+	for {
+		user = new(CreateUser)
+		err = callApi(ctx, config, tokenServer, "v1/artifacts/user/create", nil, request, user)
+		if err == nil && user.Finished() {
+			return
+		}
+		if err != nil {
+			log.Error(err.Error())
+		}
+		select {
+		case <-ctx.Done():
+			user = nil
+			err = ctx.Err()
+			return
+		case <-time.After(SubAccountPollInterval):
+		}
+	}
 }
@@ -0,0 +1,119 @@
+package vechain
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+)
+
+type staticToken string
+
+func (self staticToken) UpdateToken(ctx context.Context) error        { return nil }
+func (self staticToken) GetToken(ctx context.Context) (string, error) { return string(self), nil }
+func (self staticToken) Invalidate(token string)                      {}
+
+func TestPostArtifactOnce(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("x-api-token") != "token" {
+			w.Write([]byte(`{"code":100004,"message":"token invalid"}`))
+			return
+		}
+		if r.URL.Path != "/v1/artifacts/hashinfo/create" {
+			t.Errorf("unexpected path %s", r.URL.Path)
+		}
+		request := new(PostArtifactRequest)
+		if err := json.NewDecoder(r.Body).Decode(request); err != nil {
+			t.Errorf("invalid request body: %v", err)
+		}
+		switch request.RequestNo {
+		case "1":
+			w.Write([]byte(`{"code":1,"data":{"requestNo":"1","status":"SUCCESS","txList":[{"txid":"0x01","clauseIndex":"0","vid":"V1","dataHash":"H1"}]}}`))
+		default:
+			w.Write([]byte(`{"code":100001,"message":"param error"}`))
+		}
+	}))
+	defer server.Close()
+	cfg := &VechainConfig{SiteUrl: server.URL + "/"}
+
+	response, err := PostArtifactOnce(context.Background(), cfg, staticToken("token"), &PostArtifactRequest{RequestNo: "1"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if response.Status != "SUCCESS" || len(response.TxList) != 1 || response.TxList[0].TxId != "0x01" || response.TxList[0].DataHash != "H1" {
+		t.Errorf("unexpected response: %+v", *response)
+	}
+
+	_, err = PostArtifactOnce(context.Background(), cfg, staticToken("token"), &PostArtifactRequest{RequestNo: "2"})
+	if apiErr, ok := err.(*ApiError); !ok || apiErr.Code != 100001 {
+		t.Errorf("expect ApiError 100001, got %v", err)
+	}
+}
+
+func TestCallApi_ReauthBounded(t *testing.T) {
+	var tokens, calls int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/v1/tokens" {
+			n := atomic.AddInt32(&tokens, 1)
+			fmt.Fprintf(w, `{"code":1,"data":{"token":"T%d","expire":7200}}`, n)
+			return
+		}
+		atomic.AddInt32(&calls, 1)
+		w.Write([]byte(`{"code":100004,"message":"token invalid"}`))
+	}))
+	defer server.Close()
+	cfg := &VechainConfig{SiteUrl: server.URL + "/"}
+	token := NewDefaultToken(cfg)
+	defer token.Close()
+
+	_, err := PostArtifactOnce(context.Background(), cfg, token, &PostArtifactRequest{RequestNo: "1"})
+	if err != ErrTooManyReauth {
+		t.Errorf("expect ErrTooManyReauth, got %v", err)
+	}
+	if calls != MaxReauthAttempts+1 || tokens != MaxReauthAttempts+1 {
+		t.Errorf("expect %d calls with fresh tokens, got calls:%d tokens:%d", MaxReauthAttempts+1, calls, tokens)
+	}
+}
+
+func TestGenerateSubAccount(t *testing.T) {
+	var creates int32 //R2 的创建请求次数，重发同一 requestNo 取回结果
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/v1/artifacts/user/create" {
+			t.Errorf("unexpected path %s", r.URL.Path)
+			return
+		}
+		request := new(CreateUserRequest)
+		if err := json.NewDecoder(r.Body).Decode(request); err != nil {
+			t.Errorf("invalid request body: %v", err)
+		}
+		if request.Name != `brand "a"` {
+			t.Errorf("unexpected name: %s", request.Name)
+		}
+		if request.RequestNo == "R2" && atomic.AddInt32(&creates, 1) >= 3 {
+			fmt.Fprintf(w, `{"code":1,"data":{"requestNo":"%s","uid":"U1","status":"SUCCESS"}}`, request.RequestNo)
+			return
+		}
+		fmt.Fprintf(w, `{"code":1,"data":{"requestNo":"%s","status":"PROCESSING"}}`, request.RequestNo)
+	}))
+	defer server.Close()
+	cfg := &VechainConfig{SiteUrl: server.URL + "/"}
+
+	user, err := GenerateSubAccount(context.Background(), cfg, staticToken("token"), "R1", `brand "a"`, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if user.Status != AccountStateProcessing || user.Finished() {
+		t.Errorf("expect PROCESSING, got %+v", *user)
+	}
+
+	user, err = GenerateSubAccount(context.Background(), cfg, staticToken("token"), "R2", `brand "a"`, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if user.Uid != "U1" || user.Status != AccountStateSuccess || creates != 3 {
+		t.Errorf("expect SUCCESS after polling, got %+v creates:%d", *user, creates)
+	}
+}
@@ -0,0 +1,869 @@
+// Code generated by protoc-gen-go. DO NOT EDIT.
+// source: vechain.proto
+
+package vechainpb
+
+import (
+	context "context"
+	fmt "fmt"
+	proto "github.com/golang/protobuf/proto"
+	grpc "google.golang.org/grpc"
+	codes "google.golang.org/grpc/codes"
+	status "google.golang.org/grpc/status"
+	math "math"
+)
+
+// Reference imports to suppress errors if they are not otherwise used.
+var _ = proto.Marshal
+var _ = fmt.Errorf
+var _ = math.Inf
+
+// This is a compile-time assertion to ensure that this generated file
+// is compatible with the proto package it is being compiled against.
+// A compilation error at this line likely means your copy of the
+// proto package needs to be updated.
+const _ = proto.ProtoPackageIsVersion3 // please upgrade the proto package
+
+type SubmitRequest struct {
+	Account              string   `protobuf:"bytes,1,opt,name=account,proto3" json:"account,omitempty"`
+	Hashes               []string `protobuf:"bytes,2,rep,name=hashes,proto3" json:"hashes,omitempty"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *SubmitRequest) Reset()         { *m = SubmitRequest{} }
+func (m *SubmitRequest) String() string { return proto.CompactTextString(m) }
+func (*SubmitRequest) ProtoMessage()    {}
+func (*SubmitRequest) Descriptor() ([]byte, []int) {
+	return fileDescriptor_6a8a61548b472714, []int{0}
+}
+
+func (m *SubmitRequest) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_SubmitRequest.Unmarshal(m, b)
+}
+func (m *SubmitRequest) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_SubmitRequest.Marshal(b, m, deterministic)
+}
+func (m *SubmitRequest) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_SubmitRequest.Merge(m, src)
+}
+func (m *SubmitRequest) XXX_Size() int {
+	return xxx_messageInfo_SubmitRequest.Size(m)
+}
+func (m *SubmitRequest) XXX_DiscardUnknown() {
+	xxx_messageInfo_SubmitRequest.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_SubmitRequest proto.InternalMessageInfo
+
+func (m *SubmitRequest) GetAccount() string {
+	if m != nil {
+		return m.Account
+	}
+	return ""
+}
+
+func (m *SubmitRequest) GetHashes() []string {
+	if m != nil {
+		return m.Hashes
+	}
+	return nil
+}
+
+type SubmitResponse struct {
+	Accepted             int32    `protobuf:"varint,1,opt,name=accepted,proto3" json:"accepted,omitempty"`
+	Conflicts            []string `protobuf:"bytes,2,rep,name=conflicts,proto3" json:"conflicts,omitempty"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *SubmitResponse) Reset()         { *m = SubmitResponse{} }
+func (m *SubmitResponse) String() string { return proto.CompactTextString(m) }
+func (*SubmitResponse) ProtoMessage()    {}
+func (*SubmitResponse) Descriptor() ([]byte, []int) {
+	return fileDescriptor_6a8a61548b472714, []int{1}
+}
+
+func (m *SubmitResponse) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_SubmitResponse.Unmarshal(m, b)
+}
+func (m *SubmitResponse) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_SubmitResponse.Marshal(b, m, deterministic)
+}
+func (m *SubmitResponse) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_SubmitResponse.Merge(m, src)
+}
+func (m *SubmitResponse) XXX_Size() int {
+	return xxx_messageInfo_SubmitResponse.Size(m)
+}
+func (m *SubmitResponse) XXX_DiscardUnknown() {
+	xxx_messageInfo_SubmitResponse.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_SubmitResponse proto.InternalMessageInfo
+
+func (m *SubmitResponse) GetAccepted() int32 {
+	if m != nil {
+		return m.Accepted
+	}
+	return 0
+}
+
+func (m *SubmitResponse) GetConflicts() []string {
+	if m != nil {
+		return m.Conflicts
+	}
+	return nil
+}
+
+type GetBlockRequest struct {
+	Hash                 string   `protobuf:"bytes,1,opt,name=hash,proto3" json:"hash,omitempty"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *GetBlockRequest) Reset()         { *m = GetBlockRequest{} }
+func (m *GetBlockRequest) String() string { return proto.CompactTextString(m) }
+func (*GetBlockRequest) ProtoMessage()    {}
+func (*GetBlockRequest) Descriptor() ([]byte, []int) {
+	return fileDescriptor_6a8a61548b472714, []int{2}
+}
+
+func (m *GetBlockRequest) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_GetBlockRequest.Unmarshal(m, b)
+}
+func (m *GetBlockRequest) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_GetBlockRequest.Marshal(b, m, deterministic)
+}
+func (m *GetBlockRequest) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_GetBlockRequest.Merge(m, src)
+}
+func (m *GetBlockRequest) XXX_Size() int {
+	return xxx_messageInfo_GetBlockRequest.Size(m)
+}
+func (m *GetBlockRequest) XXX_DiscardUnknown() {
+	xxx_messageInfo_GetBlockRequest.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_GetBlockRequest proto.InternalMessageInfo
+
+func (m *GetBlockRequest) GetHash() string {
+	if m != nil {
+		return m.Hash
+	}
+	return ""
+}
+
+type Block struct {
+	Id                   int64    `protobuf:"varint,1,opt,name=id,proto3" json:"id,omitempty"`
+	Hash                 string   `protobuf:"bytes,2,opt,name=hash,proto3" json:"hash,omitempty"`
+	Vid                  string   `protobuf:"bytes,3,opt,name=vid,proto3" json:"vid,omitempty"`
+	TxId                 string   `protobuf:"bytes,4,opt,name=tx_id,json=txId,proto3" json:"tx_id,omitempty"`
+	ClauseIndex          string   `protobuf:"bytes,5,opt,name=clause_index,json=clauseIndex,proto3" json:"clause_index,omitempty"`
+	State                int32    `protobuf:"varint,6,opt,name=state,proto3" json:"state,omitempty"`
+	CurrentCommandId     int64    `protobuf:"varint,7,opt,name=current_command_id,json=currentCommandId,proto3" json:"current_command_id,omitempty"`
+	Account              string   `protobuf:"bytes,8,opt,name=account,proto3" json:"account,omitempty"`
+	BlockNumber          int64    `protobuf:"varint,9,opt,name=block_number,json=blockNumber,proto3" json:"block_number,omitempty"`
+	BlockTimestamp       int64    `protobuf:"varint,10,opt,name=block_timestamp,json=blockTimestamp,proto3" json:"block_timestamp,omitempty"`
+	Reverted             bool     `protobuf:"varint,11,opt,name=reverted,proto3" json:"reverted,omitempty"`
+	Confirmations        int64    `protobuf:"varint,12,opt,name=confirmations,proto3" json:"confirmations,omitempty"`
+	ExploreUrl           string   `protobuf:"bytes,13,opt,name=explore_url,json=exploreUrl,proto3" json:"explore_url,omitempty"`
+	Created              int64    `protobuf:"varint,14,opt,name=created,proto3" json:"created,omitempty"`
+	Updated              int64    `protobuf:"varint,15,opt,name=updated,proto3" json:"updated,omitempty"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *Block) Reset()         { *m = Block{} }
+func (m *Block) String() string { return proto.CompactTextString(m) }
+func (*Block) ProtoMessage()    {}
+func (*Block) Descriptor() ([]byte, []int) {
+	return fileDescriptor_6a8a61548b472714, []int{3}
+}
+
+func (m *Block) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_Block.Unmarshal(m, b)
+}
+func (m *Block) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_Block.Marshal(b, m, deterministic)
+}
+func (m *Block) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_Block.Merge(m, src)
+}
+func (m *Block) XXX_Size() int {
+	return xxx_messageInfo_Block.Size(m)
+}
+func (m *Block) XXX_DiscardUnknown() {
+	xxx_messageInfo_Block.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_Block proto.InternalMessageInfo
+
+func (m *Block) GetId() int64 {
+	if m != nil {
+		return m.Id
+	}
+	return 0
+}
+
+func (m *Block) GetHash() string {
+	if m != nil {
+		return m.Hash
+	}
+	return ""
+}
+
+func (m *Block) GetVid() string {
+	if m != nil {
+		return m.Vid
+	}
+	return ""
+}
+
+func (m *Block) GetTxId() string {
+	if m != nil {
+		return m.TxId
+	}
+	return ""
+}
+
+func (m *Block) GetClauseIndex() string {
+	if m != nil {
+		return m.ClauseIndex
+	}
+	return ""
+}
+
+func (m *Block) GetState() int32 {
+	if m != nil {
+		return m.State
+	}
+	return 0
+}
+
+func (m *Block) GetCurrentCommandId() int64 {
+	if m != nil {
+		return m.CurrentCommandId
+	}
+	return 0
+}
+
+func (m *Block) GetAccount() string {
+	if m != nil {
+		return m.Account
+	}
+	return ""
+}
+
+func (m *Block) GetBlockNumber() int64 {
+	if m != nil {
+		return m.BlockNumber
+	}
+	return 0
+}
+
+func (m *Block) GetBlockTimestamp() int64 {
+	if m != nil {
+		return m.BlockTimestamp
+	}
+	return 0
+}
+
+func (m *Block) GetReverted() bool {
+	if m != nil {
+		return m.Reverted
+	}
+	return false
+}
+
+func (m *Block) GetConfirmations() int64 {
+	if m != nil {
+		return m.Confirmations
+	}
+	return 0
+}
+
+func (m *Block) GetExploreUrl() string {
+	if m != nil {
+		return m.ExploreUrl
+	}
+	return ""
+}
+
+func (m *Block) GetCreated() int64 {
+	if m != nil {
+		return m.Created
+	}
+	return 0
+}
+
+func (m *Block) GetUpdated() int64 {
+	if m != nil {
+		return m.Updated
+	}
+	return 0
+}
+
+type WatchBlocksRequest struct {
+	Hashes               []string `protobuf:"bytes,1,rep,name=hashes,proto3" json:"hashes,omitempty"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *WatchBlocksRequest) Reset()         { *m = WatchBlocksRequest{} }
+func (m *WatchBlocksRequest) String() string { return proto.CompactTextString(m) }
+func (*WatchBlocksRequest) ProtoMessage()    {}
+func (*WatchBlocksRequest) Descriptor() ([]byte, []int) {
+	return fileDescriptor_6a8a61548b472714, []int{4}
+}
+
+func (m *WatchBlocksRequest) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_WatchBlocksRequest.Unmarshal(m, b)
+}
+func (m *WatchBlocksRequest) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_WatchBlocksRequest.Marshal(b, m, deterministic)
+}
+func (m *WatchBlocksRequest) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_WatchBlocksRequest.Merge(m, src)
+}
+func (m *WatchBlocksRequest) XXX_Size() int {
+	return xxx_messageInfo_WatchBlocksRequest.Size(m)
+}
+func (m *WatchBlocksRequest) XXX_DiscardUnknown() {
+	xxx_messageInfo_WatchBlocksRequest.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_WatchBlocksRequest proto.InternalMessageInfo
+
+func (m *WatchBlocksRequest) GetHashes() []string {
+	if m != nil {
+		return m.Hashes
+	}
+	return nil
+}
+
+type BlockEvent struct {
+	Hash                 string   `protobuf:"bytes,1,opt,name=hash,proto3" json:"hash,omitempty"`
+	Vid                  string   `protobuf:"bytes,2,opt,name=vid,proto3" json:"vid,omitempty"`
+	TxId                 string   `protobuf:"bytes,3,opt,name=tx_id,json=txId,proto3" json:"tx_id,omitempty"`
+	ExploreUrl           string   `protobuf:"bytes,4,opt,name=explore_url,json=exploreUrl,proto3" json:"explore_url,omitempty"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *BlockEvent) Reset()         { *m = BlockEvent{} }
+func (m *BlockEvent) String() string { return proto.CompactTextString(m) }
+func (*BlockEvent) ProtoMessage()    {}
+func (*BlockEvent) Descriptor() ([]byte, []int) {
+	return fileDescriptor_6a8a61548b472714, []int{5}
+}
+
+func (m *BlockEvent) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_BlockEvent.Unmarshal(m, b)
+}
+func (m *BlockEvent) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_BlockEvent.Marshal(b, m, deterministic)
+}
+func (m *BlockEvent) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_BlockEvent.Merge(m, src)
+}
+func (m *BlockEvent) XXX_Size() int {
+	return xxx_messageInfo_BlockEvent.Size(m)
+}
+func (m *BlockEvent) XXX_DiscardUnknown() {
+	xxx_messageInfo_BlockEvent.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_BlockEvent proto.InternalMessageInfo
+
+func (m *BlockEvent) GetHash() string {
+	if m != nil {
+		return m.Hash
+	}
+	return ""
+}
+
+func (m *BlockEvent) GetVid() string {
+	if m != nil {
+		return m.Vid
+	}
+	return ""
+}
+
+func (m *BlockEvent) GetTxId() string {
+	if m != nil {
+		return m.TxId
+	}
+	return ""
+}
+
+func (m *BlockEvent) GetExploreUrl() string {
+	if m != nil {
+		return m.ExploreUrl
+	}
+	return ""
+}
+
+type ListCommandsRequest struct {
+	State                string   `protobuf:"bytes,1,opt,name=state,proto3" json:"state,omitempty"`
+	Limit                int32    `protobuf:"varint,2,opt,name=limit,proto3" json:"limit,omitempty"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *ListCommandsRequest) Reset()         { *m = ListCommandsRequest{} }
+func (m *ListCommandsRequest) String() string { return proto.CompactTextString(m) }
+func (*ListCommandsRequest) ProtoMessage()    {}
+func (*ListCommandsRequest) Descriptor() ([]byte, []int) {
+	return fileDescriptor_6a8a61548b472714, []int{6}
+}
+
+func (m *ListCommandsRequest) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_ListCommandsRequest.Unmarshal(m, b)
+}
+func (m *ListCommandsRequest) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_ListCommandsRequest.Marshal(b, m, deterministic)
+}
+func (m *ListCommandsRequest) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_ListCommandsRequest.Merge(m, src)
+}
+func (m *ListCommandsRequest) XXX_Size() int {
+	return xxx_messageInfo_ListCommandsRequest.Size(m)
+}
+func (m *ListCommandsRequest) XXX_DiscardUnknown() {
+	xxx_messageInfo_ListCommandsRequest.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_ListCommandsRequest proto.InternalMessageInfo
+
+func (m *ListCommandsRequest) GetState() string {
+	if m != nil {
+		return m.State
+	}
+	return ""
+}
+
+func (m *ListCommandsRequest) GetLimit() int32 {
+	if m != nil {
+		return m.Limit
+	}
+	return 0
+}
+
+type Command struct {
+	Id                   int64    `protobuf:"varint,1,opt,name=id,proto3" json:"id,omitempty"`
+	Cmd                  string   `protobuf:"bytes,2,opt,name=cmd,proto3" json:"cmd,omitempty"`
+	State                string   `protobuf:"bytes,3,opt,name=state,proto3" json:"state,omitempty"`
+	Error                string   `protobuf:"bytes,4,opt,name=error,proto3" json:"error,omitempty"`
+	Account              string   `protobuf:"bytes,5,opt,name=account,proto3" json:"account,omitempty"`
+	Created              int64    `protobuf:"varint,6,opt,name=created,proto3" json:"created,omitempty"`
+	Updated              int64    `protobuf:"varint,7,opt,name=updated,proto3" json:"updated,omitempty"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *Command) Reset()         { *m = Command{} }
+func (m *Command) String() string { return proto.CompactTextString(m) }
+func (*Command) ProtoMessage()    {}
+func (*Command) Descriptor() ([]byte, []int) {
+	return fileDescriptor_6a8a61548b472714, []int{7}
+}
+
+func (m *Command) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_Command.Unmarshal(m, b)
+}
+func (m *Command) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_Command.Marshal(b, m, deterministic)
+}
+func (m *Command) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_Command.Merge(m, src)
+}
+func (m *Command) XXX_Size() int {
+	return xxx_messageInfo_Command.Size(m)
+}
+func (m *Command) XXX_DiscardUnknown() {
+	xxx_messageInfo_Command.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_Command proto.InternalMessageInfo
+
+func (m *Command) GetId() int64 {
+	if m != nil {
+		return m.Id
+	}
+	return 0
+}
+
+func (m *Command) GetCmd() string {
+	if m != nil {
+		return m.Cmd
+	}
+	return ""
+}
+
+func (m *Command) GetState() string {
+	if m != nil {
+		return m.State
+	}
+	return ""
+}
+
+func (m *Command) GetError() string {
+	if m != nil {
+		return m.Error
+	}
+	return ""
+}
+
+func (m *Command) GetAccount() string {
+	if m != nil {
+		return m.Account
+	}
+	return ""
+}
+
+func (m *Command) GetCreated() int64 {
+	if m != nil {
+		return m.Created
+	}
+	return 0
+}
+
+func (m *Command) GetUpdated() int64 {
+	if m != nil {
+		return m.Updated
+	}
+	return 0
+}
+
+type ListCommandsResponse struct {
+	Commands             []*Command `protobuf:"bytes,1,rep,name=commands,proto3" json:"commands,omitempty"`
+	XXX_NoUnkeyedLiteral struct{}   `json:"-"`
+	XXX_unrecognized     []byte     `json:"-"`
+	XXX_sizecache        int32      `json:"-"`
+}
+
+func (m *ListCommandsResponse) Reset()         { *m = ListCommandsResponse{} }
+func (m *ListCommandsResponse) String() string { return proto.CompactTextString(m) }
+func (*ListCommandsResponse) ProtoMessage()    {}
+func (*ListCommandsResponse) Descriptor() ([]byte, []int) {
+	return fileDescriptor_6a8a61548b472714, []int{8}
+}
+
+func (m *ListCommandsResponse) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_ListCommandsResponse.Unmarshal(m, b)
+}
+func (m *ListCommandsResponse) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_ListCommandsResponse.Marshal(b, m, deterministic)
+}
+func (m *ListCommandsResponse) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_ListCommandsResponse.Merge(m, src)
+}
+func (m *ListCommandsResponse) XXX_Size() int {
+	return xxx_messageInfo_ListCommandsResponse.Size(m)
+}
+func (m *ListCommandsResponse) XXX_DiscardUnknown() {
+	xxx_messageInfo_ListCommandsResponse.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_ListCommandsResponse proto.InternalMessageInfo
+
+func (m *ListCommandsResponse) GetCommands() []*Command {
+	if m != nil {
+		return m.Commands
+	}
+	return nil
+}
+
+func init() {
+	proto.RegisterType((*SubmitRequest)(nil), "vechain.SubmitRequest")
+	proto.RegisterType((*SubmitResponse)(nil), "vechain.SubmitResponse")
+	proto.RegisterType((*GetBlockRequest)(nil), "vechain.GetBlockRequest")
+	proto.RegisterType((*Block)(nil), "vechain.Block")
+	proto.RegisterType((*WatchBlocksRequest)(nil), "vechain.WatchBlocksRequest")
+	proto.RegisterType((*BlockEvent)(nil), "vechain.BlockEvent")
+	proto.RegisterType((*ListCommandsRequest)(nil), "vechain.ListCommandsRequest")
+	proto.RegisterType((*Command)(nil), "vechain.Command")
+	proto.RegisterType((*ListCommandsResponse)(nil), "vechain.ListCommandsResponse")
+}
+
+func init() {
+	proto.RegisterFile("vechain.proto", fileDescriptor_6a8a61548b472714)
+}
+
+var fileDescriptor_6a8a61548b472714 = []byte{
+	// 643 bytes of a gzipped FileDescriptorProto
+	0x1f, 0x8b, 0x08, 0x00, 0x00, 0x00, 0x00, 0x00, 0x02, 0xff, 0x74, 0x54, 0xcb, 0x6e, 0xd3, 0x4c,
+	0x14, 0x56, 0xe2, 0x3a, 0x97, 0x93, 0x4b, 0xa3, 0x69, 0xd5, 0xdf, 0xca, 0x5f, 0x44, 0x6b, 0x81,
+	0xe8, 0xa2, 0x6a, 0xa1, 0xb0, 0x41, 0xac, 0x5a, 0x40, 0xa8, 0x80, 0x58, 0x98, 0x9b, 0xc4, 0x26,
+	0x72, 0xc6, 0x53, 0x32, 0xc2, 0x63, 0x9b, 0xf1, 0x38, 0x0a, 0xaf, 0xc0, 0x7b, 0xf0, 0x0a, 0x3c,
+	0x1f, 0x9a, 0x6b, 0xec, 0x34, 0x5d, 0xc5, 0xe7, 0x3b, 0xdf, 0x9c, 0xcb, 0x7c, 0x5f, 0x06, 0x46,
+	0x4b, 0x82, 0x17, 0x31, 0xcd, 0xce, 0x0a, 0x9e, 0x8b, 0x1c, 0x75, 0x4d, 0x18, 0x5e, 0xc2, 0xe8,
+	0x63, 0x35, 0x67, 0x54, 0x44, 0xe4, 0x67, 0x45, 0x4a, 0x81, 0x02, 0xe8, 0xc6, 0x18, 0xe7, 0x55,
+	0x26, 0x82, 0xd6, 0x51, 0xeb, 0xa4, 0x1f, 0xd9, 0x10, 0x1d, 0x40, 0x67, 0x11, 0x97, 0x0b, 0x52,
+	0x06, 0xed, 0x23, 0xef, 0xa4, 0x1f, 0x99, 0x28, 0x7c, 0x0b, 0x63, 0x5b, 0xa2, 0x2c, 0xf2, 0xac,
+	0x24, 0x68, 0x0a, 0xbd, 0x18, 0x63, 0x52, 0x08, 0x92, 0xa8, 0x22, 0x7e, 0xe4, 0x62, 0x74, 0x08,
+	0x7d, 0x9c, 0x67, 0x37, 0x29, 0xc5, 0xc2, 0x16, 0x5a, 0x03, 0xe1, 0x43, 0xd8, 0x7d, 0x43, 0xc4,
+	0x55, 0x9a, 0xe3, 0x1f, 0x76, 0x20, 0x04, 0x3b, 0xb2, 0x91, 0x99, 0x46, 0x7d, 0x87, 0x7f, 0x3d,
+	0xf0, 0x15, 0x09, 0x8d, 0xa1, 0x4d, 0x75, 0x13, 0x2f, 0x6a, 0xd3, 0xc4, 0xb1, 0xdb, 0x6b, 0x36,
+	0x9a, 0x80, 0xb7, 0xa4, 0x49, 0xe0, 0x29, 0x48, 0x7e, 0xa2, 0x3d, 0xf0, 0xc5, 0x6a, 0x46, 0x93,
+	0x60, 0x47, 0xd3, 0xc4, 0xea, 0x3a, 0x41, 0xc7, 0x30, 0xc4, 0x69, 0x5c, 0x95, 0x64, 0x46, 0xb3,
+	0x84, 0xac, 0x02, 0x5f, 0xe5, 0x06, 0x1a, 0xbb, 0x96, 0x10, 0xda, 0x07, 0xbf, 0x14, 0xb1, 0x20,
+	0x41, 0x47, 0x6d, 0xa5, 0x03, 0x74, 0x0a, 0x08, 0x57, 0x9c, 0x93, 0x4c, 0xcc, 0x70, 0xce, 0x58,
+	0x9c, 0x25, 0xb2, 0x74, 0x57, 0xcd, 0x34, 0x31, 0x99, 0x97, 0x3a, 0x71, 0x9d, 0xd4, 0x2f, 0xb8,
+	0xd7, 0xbc, 0xe0, 0x63, 0x18, 0xce, 0xe5, 0x52, 0xb3, 0xac, 0x62, 0x73, 0xc2, 0x83, 0xbe, 0xaa,
+	0x30, 0x50, 0xd8, 0x07, 0x05, 0xa1, 0x47, 0xb0, 0xab, 0x29, 0x82, 0x32, 0x52, 0x8a, 0x98, 0x15,
+	0x01, 0x28, 0xd6, 0x58, 0xc1, 0x9f, 0x2c, 0x2a, 0x25, 0xe0, 0x64, 0x49, 0xb8, 0x94, 0x60, 0x70,
+	0xd4, 0x3a, 0xe9, 0x45, 0x2e, 0x46, 0x0f, 0x60, 0x24, 0x6f, 0x9c, 0x72, 0x16, 0x0b, 0x9a, 0x67,
+	0x65, 0x30, 0x54, 0x25, 0x9a, 0x20, 0xba, 0x0f, 0x03, 0xb2, 0x2a, 0xd2, 0x9c, 0x93, 0x59, 0xc5,
+	0xd3, 0x60, 0xa4, 0x66, 0x05, 0x03, 0x7d, 0xe6, 0xa9, 0x5c, 0x04, 0x73, 0x12, 0xcb, 0x0e, 0x63,
+	0x55, 0xc0, 0x86, 0x32, 0x53, 0x15, 0x89, 0xca, 0xec, 0xea, 0x8c, 0x09, 0xc3, 0x53, 0x40, 0x5f,
+	0x63, 0x81, 0x17, 0x4a, 0xbc, 0xd2, 0x4a, 0xbc, 0x76, 0x56, 0xab, 0xe1, 0xac, 0x05, 0x80, 0x22,
+	0xbe, 0x5e, 0x92, 0x6c, 0xab, 0x11, 0xac, 0xb4, 0xed, 0x2d, 0xd2, 0x7a, 0x35, 0x69, 0x37, 0x76,
+	0xd9, 0xd9, 0xdc, 0x25, 0xbc, 0x84, 0xbd, 0xf7, 0xb4, 0xb4, 0x2a, 0xb9, 0xc1, 0x9c, 0xde, 0xba,
+	0xa7, 0x0e, 0x24, 0x9a, 0x52, 0x46, 0x85, 0x6a, 0xeb, 0x47, 0x3a, 0x08, 0xff, 0xb4, 0xa0, 0x6b,
+	0xce, 0xdf, 0x72, 0xe5, 0x04, 0x3c, 0xcc, 0xdc, 0x98, 0x98, 0x25, 0xeb, 0xca, 0xde, 0x46, 0x65,
+	0xc2, 0x79, 0xce, 0xcd, 0x84, 0x3a, 0xa8, 0x3b, 0xc6, 0x6f, 0x3a, 0xa6, 0x26, 0x41, 0xe7, 0x4e,
+	0x09, 0xba, 0x4d, 0x09, 0x5e, 0xc1, 0x7e, 0x73, 0x55, 0xf3, 0xa7, 0x3d, 0x85, 0x9e, 0x71, 0xaf,
+	0x96, 0x61, 0x70, 0x31, 0x39, 0xb3, 0x8f, 0x86, 0x21, 0x47, 0x8e, 0x71, 0xf1, 0xbb, 0x0d, 0xdd,
+	0x2f, 0x3a, 0x8b, 0x9e, 0x43, 0x47, 0x3f, 0x00, 0xe8, 0xc0, 0x9d, 0x68, 0x3c, 0x2a, 0xd3, 0xff,
+	0x6e, 0xe1, 0xa6, 0xe9, 0x33, 0xe8, 0xd9, 0xff, 0x3b, 0x0a, 0x1c, 0x69, 0xe3, 0x09, 0x98, 0x8e,
+	0x5d, 0x46, 0x33, 0x2f, 0x61, 0x50, 0x73, 0x11, 0xfa, 0xdf, 0xa5, 0x6f, 0x7b, 0x6b, 0xba, 0xd7,
+	0x3c, 0xab, 0xac, 0xf4, 0xb8, 0x85, 0xde, 0xc1, 0xb0, 0x7e, 0x0b, 0xe8, 0xd0, 0xd1, 0xb6, 0xf8,
+	0x60, 0x7a, 0xef, 0x8e, 0xac, 0xde, 0xe2, 0xea, 0xc9, 0xb7, 0xf3, 0xef, 0x54, 0x2c, 0xaa, 0xf9,
+	0x19, 0xce, 0xd9, 0x39, 0xfb, 0x15, 0xdf, 0x10, 0x4a, 0xf8, 0xb9, 0x39, 0x63, 0x7f, 0x8b, 0xf9,
+	0x0b, 0xf7, 0x35, 0xef, 0xa8, 0x77, 0xf8, 0xe9, 0xbf, 0x01, 0x00, 0x86, 0x85, 0xdb, 0xe9, 0x98,
+	0x05, 0x00, 0x00,
+}
+
+// Reference imports to suppress errors if they are not otherwise used.
+var _ context.Context
+var _ grpc.ClientConnInterface
+
+// This is a compile-time assertion to ensure that this generated file
+// is compatible with the grpc package it is being compiled against.
+const _ = grpc.SupportPackageIsVersion6
+
+// VechainClient is the client API for Vechain service.
+//
+// For semantics around ctx use and closing/ending streaming RPCs, please refer to https://godoc.org/google.golang.org/grpc#ClientConn.NewStream.
+type VechainClient interface {
+	// 异步提交 hash 上链（幂等）
+	Submit(ctx context.Context, in *SubmitRequest, opts ...grpc.CallOption) (*SubmitResponse, error)
+	// 查询 hash 的区块信息
+	GetBlock(ctx context.Context, in *GetBlockRequest, opts ...grpc.CallOption) (*Block, error)
+	// 订阅上链成功事件，直到客户端断开
+	WatchBlocks(ctx context.Context, in *WatchBlocksRequest, opts ...grpc.CallOption) (Vechain_WatchBlocksClient, error)
+	// 按状态列出命令
+	ListCommands(ctx context.Context, in *ListCommandsRequest, opts ...grpc.CallOption) (*ListCommandsResponse, error)
+}
+
+type vechainClient struct {
+	cc grpc.ClientConnInterface
+}
+
+func NewVechainClient(cc grpc.ClientConnInterface) VechainClient {
+	return &vechainClient{cc}
+}
+
+func (c *vechainClient) Submit(ctx context.Context, in *SubmitRequest, opts ...grpc.CallOption) (*SubmitResponse, error) {
+	out := new(SubmitResponse)
+	err := c.cc.Invoke(ctx, "/vechain.Vechain/Submit", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *vechainClient) GetBlock(ctx context.Context, in *GetBlockRequest, opts ...grpc.CallOption) (*Block, error) {
+	out := new(Block)
+	err := c.cc.Invoke(ctx, "/vechain.Vechain/GetBlock", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *vechainClient) WatchBlocks(ctx context.Context, in *WatchBlocksRequest, opts ...grpc.CallOption) (Vechain_WatchBlocksClient, error) {
+	stream, err := c.cc.NewStream(ctx, &_Vechain_serviceDesc.Streams[0], "/vechain.Vechain/WatchBlocks", opts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &vechainWatchBlocksClient{stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+type Vechain_WatchBlocksClient interface {
+	Recv() (*BlockEvent, error)
+	grpc.ClientStream
+}
+
+type vechainWatchBlocksClient struct {
+	grpc.ClientStream
+}
+
+func (x *vechainWatchBlocksClient) Recv() (*BlockEvent, error) {
+	m := new(BlockEvent)
+	if err := x.ClientStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+func (c *vechainClient) ListCommands(ctx context.Context, in *ListCommandsRequest, opts ...grpc.CallOption) (*ListCommandsResponse, error) {
+	out := new(ListCommandsResponse)
+	err := c.cc.Invoke(ctx, "/vechain.Vechain/ListCommands", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// VechainServer is the server API for Vechain service.
+type VechainServer interface {
+	// 异步提交 hash 上链（幂等）
+	Submit(context.Context, *SubmitRequest) (*SubmitResponse, error)
+	// 查询 hash 的区块信息
+	GetBlock(context.Context, *GetBlockRequest) (*Block, error)
+	// 订阅上链成功事件，直到客户端断开
+	WatchBlocks(*WatchBlocksRequest, Vechain_WatchBlocksServer) error
+	// 按状态列出命令
+	ListCommands(context.Context, *ListCommandsRequest) (*ListCommandsResponse, error)
+}
+
+// UnimplementedVechainServer can be embedded to have forward compatible implementations.
+type UnimplementedVechainServer struct {
+}
+
+func (*UnimplementedVechainServer) Submit(ctx context.Context, req *SubmitRequest) (*SubmitResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Submit not implemented")
+}
+func (*UnimplementedVechainServer) GetBlock(ctx context.Context, req *GetBlockRequest) (*Block, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetBlock not implemented")
+}
+func (*UnimplementedVechainServer) WatchBlocks(req *WatchBlocksRequest, srv Vechain_WatchBlocksServer) error {
+	return status.Errorf(codes.Unimplemented, "method WatchBlocks not implemented")
+}
+func (*UnimplementedVechainServer) ListCommands(ctx context.Context, req *ListCommandsRequest) (*ListCommandsResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method ListCommands not implemented")
+}
+
+func RegisterVechainServer(s *grpc.Server, srv VechainServer) {
+	s.RegisterService(&_Vechain_serviceDesc, srv)
+}
+
+func _Vechain_Submit_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(SubmitRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(VechainServer).Submit(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/vechain.Vechain/Submit",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(VechainServer).Submit(ctx, req.(*SubmitRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Vechain_GetBlock_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(GetBlockRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(VechainServer).GetBlock(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/vechain.Vechain/GetBlock",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(VechainServer).GetBlock(ctx, req.(*GetBlockRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Vechain_WatchBlocks_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(WatchBlocksRequest)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(VechainServer).WatchBlocks(m, &vechainWatchBlocksServer{stream})
+}
+
+type Vechain_WatchBlocksServer interface {
+	Send(*BlockEvent) error
+	grpc.ServerStream
+}
+
+type vechainWatchBlocksServer struct {
+	grpc.ServerStream
+}
+
+func (x *vechainWatchBlocksServer) Send(m *BlockEvent) error {
+	return x.ServerStream.SendMsg(m)
+}
+
+func _Vechain_ListCommands_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ListCommandsRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(VechainServer).ListCommands(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/vechain.Vechain/ListCommands",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(VechainServer).ListCommands(ctx, req.(*ListCommandsRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+var _Vechain_serviceDesc = grpc.ServiceDesc{
+	ServiceName: "vechain.Vechain",
+	HandlerType: (*VechainServer)(nil),
+	Methods: []grpc.MethodDesc{
+		{
+			MethodName: "Submit",
+			Handler:    _Vechain_Submit_Handler,
+		},
+		{
+			MethodName: "GetBlock",
+			Handler:    _Vechain_GetBlock_Handler,
+		},
+		{
+			MethodName: "ListCommands",
+			Handler:    _Vechain_ListCommands_Handler,
+		},
+	},
+	Streams: []grpc.StreamDesc{
+		{
+			StreamName:    "WatchBlocks",
+			Handler:       _Vechain_WatchBlocks_Handler,
+			ServerStreams: true,
+		},
+	},
+	Metadata: "vechain.proto",
+}
@@ -0,0 +1,192 @@
+package vechain
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"fmt"
+	"strings"
+	"sync"
+	"unicode"
+
+	"github.com/myafeier/log"
+	"xorm.io/xorm"
+)
+
+// ============vid 生成============
+// 配置了 VeVid 时 vid 为 VeVid.后缀，否则沿用原有的 0X + 64 位摘要；由 VidStrategy 选择生成方式
+
+const (
+	VidStrategyRandom        = "random"        //默认，hash + 随机数的摘要
+	VidStrategyDeterministic = "deterministic" //hash + 抢占次数的摘要，同一 hash 的第 n 次抢占总是得到相同的 vid
+	VidStrategySequential    = "sequential"    //数据库计数器递增
+
+	VidMaxLength        = 100 //与 vechain_block.vid 字段长度一致
+	VidSequenceStep     = 100 //顺序生成时每次从数据库预留的个数
+	vidLegacyPrefix     = "0X"
+	vidDigestLength     = 32 //配置了 VeVid 时摘要取前 32 个十六进制字符，未配置时保留完整摘要
+	vidSequenceDigits   = 12
+	vidSequenceRowName  = "vid"
+	vidNamespaceDivider = "."
+)
+
+// VidGenerator 为区块生成待抢占的 vid
+type VidGenerator interface {
+	// attempt 为该区块的抢占次数，从 0 开始，抢占失败重新生成时加 1
+	Generate(b *Block, attempt int) (vid string, err error)
+}
+
+// 按配置新建 vid 生成器，engine 仅顺序生成时使用
+func NewVidGenerator(config *VechainConfig, engine *xorm.Engine) (generator VidGenerator, err error) {
+	prefix := vidPrefix(config.VeVid)
+	switch config.VidStrategy {
+	case VidStrategyRandom, "":
+		generator = &RandomVidGenerator{Prefix: prefix}
+	case VidStrategyDeterministic:
+		generator = &DeterministicVidGenerator{Prefix: prefix}
+	case VidStrategySequential:
+		generator = NewSequentialVidGenerator(engine, prefix)
+	default:
+		err = fmt.Errorf("invalid VidStrategy: %s", config.VidStrategy)
+	}
+	return
+}
+
+//VeVid 为空时使用 0X 前缀
+func vidPrefix(veVid string) string {
+	veVid = strings.TrimSuffix(strings.TrimSpace(veVid), vidNamespaceDivider)
+	if veVid == "" {
+		return vidLegacyPrefix
+	}
+	return veVid + vidNamespaceDivider
+}
+
+// 本地的 vid 检查：非空、不超过 vechain_block.vid 字段长度、不含空白和控制字符
+//  平台文档未给出 vid 的格式约束，这里只拦截明显错误的自定义生成结果
+func ValidateVid(vid string) error {
+	if len(vid) == 0 || len(vid) > VidMaxLength {
+		return fmt.Errorf("vid length must be between 1 and %d: %q", VidMaxLength, vid)
+	}
+	if strings.IndexFunc(vid, func(r rune) bool { return unicode.IsSpace(r) || unicode.IsControl(r) }) >= 0 {
+		return fmt.Errorf("vid must not contain whitespace or control characters: %q", vid)
+	}
+	return nil
+}
+
+// RandomVidGenerator 前缀 + sha256(hash + 随机数) 的摘要
+type RandomVidGenerator struct {
+	Prefix string
+}
+
+func (self *RandomVidGenerator) Generate(b *Block, attempt int) (vid string, err error) {
+	nonce := make([]byte, 16)
+	_, err = rand.Read(nonce)
+	if err != nil {
+		return
+	}
+	return vidDigest(self.Prefix, append([]byte(b.Hash), nonce...)), nil
+}
+
+// DeterministicVidGenerator 前缀 + sha256(hash:attempt) 的摘要，便于重放和核对
+type DeterministicVidGenerator struct {
+	Prefix string
+}
+
+func (self *DeterministicVidGenerator) Generate(b *Block, attempt int) (vid string, err error) {
+	return vidDigest(self.Prefix, []byte(fmt.Sprintf("%s:%d", b.Hash, attempt))), nil
+}
+
+//未配置 VeVid 时与原有 vid 一致，保留完整的 64 位摘要
+func vidDigest(prefix string, data []byte) string {
+	digest := fmt.Sprintf("%X", sha256.Sum256(data))
+	if prefix != vidLegacyPrefix {
+		digest = digest[:vidDigestLength]
+	}
+	return prefix + digest
+}
+
+// SequentialVidGenerator 前缀 + 12 位递增序号，计数器保存在 vechain_vid_sequence
+//  每次从数据库预留 VidSequenceStep 个序号，重启后未用完的序号跳过
+type SequentialVidGenerator struct {
+	Prefix string
+	engine *xorm.Engine
+	mutex  sync.Mutex
+	next   int64
+	limit  int64
+}
+
+// VidSequence 顺序生成 vid 的计数器
+type VidSequence struct {
+	Id    int64  `json:"id"`
+	Name  string `json:"name" xorm:"varchar(50) default '' unique"`
+	Value int64  `json:"value" xorm:"default 0"` //已预留的最大序号
+}
+
+func (self *VidSequence) TableName() string {
+	return "vechain_vid_sequence"
+}
+
+func NewSequentialVidGenerator(engine *xorm.Engine, prefix string) *SequentialVidGenerator {
+	return &SequentialVidGenerator{Prefix: prefix, engine: engine}
+}
+
+func (self *SequentialVidGenerator) Generate(b *Block, attempt int) (vid string, err error) {
+	self.mutex.Lock()
+	defer self.mutex.Unlock()
+	if self.next == 0 || self.next > self.limit {
+		self.limit, err = self.reserve()
+		if err != nil {
+			return
+		}
+		self.next = self.limit - VidSequenceStep + 1
+	}
+	vid = fmt.Sprintf("%s%0*d", self.Prefix, vidSequenceDigits, self.next)
+	self.next++
+	return
+}
+
+//在事务中把计数器加 VidSequenceStep，返回新的最大序号
+func (self *SequentialVidGenerator) reserve() (limit int64, err error) {
+	session := self.engine.NewSession()
+	defer session.Close()
+	err = session.Begin()
+	if err != nil {
+		log.Error("%+v", err.Error())
+		return
+	}
+	defer func() {
+		if err != nil {
+			session.Rollback()
+		} else {
+			err = session.Commit()
+		}
+	}()
+	seq := new(VidSequence)
+	has, err := session.Where("name=?", vidSequenceRowName).ForUpdate().Get(seq)
+	if err != nil {
+		log.Error("%+v", err.Error())
+		return
+	}
+	seq.Value += VidSequenceStep
+	if has {
+		_, err = session.ID(seq.Id).Cols("value").Update(seq)
+	} else {
+		seq.Name = vidSequenceRowName
+		_, err = session.Insert(seq)
+	}
+	if err != nil {
+		log.Error("%+v", err.Error())
+		return
+	}
+	limit = seq.Value
+	return
+}
+
+var defaultVidGenerator VidGenerator = &RandomVidGenerator{Prefix: vidLegacyPrefix}
+
+//服务使用的 vid 生成方式，未设置 VidGenerator 时使用默认生成方式
+func (s *Service) vidGenerator() VidGenerator {
+	if s.VidGenerator != nil {
+		return s.VidGenerator
+	}
+	return defaultVidGenerator
+}
@@ -0,0 +1,386 @@
+package vechain
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/myafeier/log"
+	"xorm.io/xorm"
+)
+
+// ============预抢占 vid 池============
+// 抢占需要轮询平台直到不再是 GENERATING，耗时较长；后台提前抢占 vid 存入 vechain_vid_pool，
+// 提交时直接分配池中的 vid 并进入上链阶段，池不足的部分仍按原流程抢占
+
+const (
+	VidPoolCheckDuration    = 1 * time.Minute  //检查池水位的时间间隔
+	VidPoolOccupyingTimeout = 10 * time.Minute //抢占命令不在本进程运行时，抢占中的 vid 超过此时间视为命令已随进程退出
+	vidPoolHashPrefix       = "vid-pool-"      //池中 vid 没有对应的 hash，生成 vid 时以 vid-pool-命令id-序号 代替
+)
+
+type VidPoolState int
+
+const (
+	VidPoolStateOccupying VidPoolState = 1 //抢占中
+	VidPoolStateAvailable VidPoolState = 2 //已抢占，可分配
+	VidPoolStateAssigned  VidPoolState = 3 //已分配给区块
+	VidPoolStateFailed    VidPoolState = 4 //抢占命令失败，重试命令成功后变为可分配
+)
+
+// PooledVid 池中的 vid
+type PooledVid struct {
+	CommonModel `json:",inline" xorm:"extends"`
+	Vid         string       `json:"vid" xorm:"varchar(100) default '' unique"`
+	ScanUrl     string       `json:"scan_url" xorm:"varchar(500) default ''"` //抢占接口返回的 url，同一抢占请求的 vid 相同
+	State       VidPoolState `json:"state" xorm:"tinyint(2) default 0 index"`
+	CommandId   int64        `json:"command_id" xorm:"default 0 index"`   //抢占命令id
+	Hash        string       `json:"hash" xorm:"varchar(100) default ''"` //分配给的区块 hash
+}
+
+func (self *PooledVid) TableName() string {
+	return "vechain_vid_pool"
+}
+
+// VidPoolStats 池中各状态的 vid 数量
+type VidPoolStats struct {
+	Occupying int64 `json:"occupying"`
+	Available int64 `json:"available"`
+	Assigned  int64 `json:"assigned"`
+	Failed    int64 `json:"failed"`
+}
+
+// 池中各状态的 vid 数量
+func (s *Service) VidPoolStats() (stats *VidPoolStats, err error) {
+	stats = new(VidPoolStats)
+	session := s.dbEngine.NewSession()
+	defer session.Close()
+	for state, n := range map[VidPoolState]*int64{
+		VidPoolStateOccupying: &stats.Occupying,
+		VidPoolStateAvailable: &stats.Available,
+		VidPoolStateAssigned:  &stats.Assigned,
+		VidPoolStateFailed:    &stats.Failed,
+	} {
+		*n, err = session.Where("state=?", state).Count(&PooledVid{})
+		if err != nil {
+			log.Error("%+v", err.Error())
+			return
+		}
+	}
+	return
+}
+
+var vidPoolMutex sync.Mutex
+
+// 可分配和抢占中的 vid 不超过 VidPoolLowWatermark 时，发起抢占补足到 VidPoolHighWatermark，返回新抢占的 vid 数
+//  未启用池（VidPoolHighWatermark 为 0）时不做任何事
+func (s *Service) FillVidPool() (n int, err error) {
+	if s.config.VidPoolHighWatermark <= 0 {
+		return
+	}
+	vidPoolMutex.Lock()
+	defer vidPoolMutex.Unlock()
+	err = s.failStalePooledVids()
+	if err != nil {
+		return
+	}
+	stats, err := s.VidPoolStats()
+	if err != nil {
+		return
+	}
+	pending := int(stats.Available + stats.Occupying)
+	if pending > s.config.VidPoolLowWatermark {
+		return
+	}
+	n = s.config.VidPoolHighWatermark - pending
+	cmds, err := s.newFillVidPoolCommands(n)
+	if err != nil {
+		n = 0
+		return
+	}
+	for _, v := range cmds {
+		s.RunningCommandIds.Store(v.GetId(), true)
+		s.CommandChan <- v
+	}
+	log.Info("fill vid pool: available:%d occupying:%d new:%d", stats.Available, stats.Occupying, n)
+	return
+}
+
+//抢占命令已随进程退出的 vid 标记为失败，不再计入水位，可通过 RetryCommand 重试
+//  抢占命令不在本进程运行且超过 VidPoolOccupyingTimeout，或超过命令超时时间的视为已退出；
+//  误判为失败的命令之后成功时 vid 仍会变为可分配
+func (s *Service) failStalePooledVids() (err error) {
+	timeout := VidPoolOccupyingTimeout
+	if s.config.CommandExpireDuration < timeout {
+		timeout = s.config.CommandExpireDuration
+	}
+	var stale []*PooledVid
+	err = s.dbEngine.Where("state=? and updated<?", VidPoolStateOccupying, time.Now().Add(-timeout)).Cols("command_id", "updated").Find(&stale)
+	if err != nil {
+		log.Error("%+v", err.Error())
+		return
+	}
+	expired := time.Now().Add(-s.config.CommandExpireDuration)
+	var commandIds []int64
+	seen := make(map[int64]bool)
+	for _, v := range stale {
+		if seen[v.CommandId] {
+			continue
+		}
+		seen[v.CommandId] = true
+		if _, running := s.RunningCommandIds.Load(v.CommandId); running && v.Updated.After(expired) {
+			continue
+		}
+		commandIds = append(commandIds, v.CommandId)
+	}
+	if len(commandIds) == 0 {
+		return
+	}
+	_, err = s.dbEngine.Where("state=? and updated<?", VidPoolStateOccupying, time.Now().Add(-timeout)).In("command_id", commandIds).
+		Cols("state").Update(&PooledVid{State: VidPoolStateFailed})
+	if err != nil {
+		log.Error("%+v", err.Error())
+		return
+	}
+	log.Info("vid pool: mark occupying vids of commands %v failed", commandIds)
+	return
+}
+
+//按 ItemAmountPerRequest 分组创建抢占命令
+func (s *Service) newFillVidPoolCommands(amount int) (cmds []ICommand, err error) {
+	persistMutex.Lock()
+	defer persistMutex.Unlock()
+	session := s.dbEngine.NewSession()
+	defer session.Close()
+	err = session.Begin()
+	if err != nil {
+		log.Error("%+v", err.Error())
+		return
+	}
+	defer func() {
+		if err != nil {
+			session.Rollback()
+			cmds = nil
+		} else {
+			err = session.Commit()
+		}
+	}()
+	for amount > 0 {
+		size := s.config.ItemAmountPerRequest
+		if amount < size {
+			size = amount
+		}
+		var cmd ICommand
+		cmd, err = NewFillVidPoolCommand(session, newCommandContext(s.config.CommandExpireDuration), s.vidGenerator(), size)
+		if err != nil {
+			return
+		}
+		cmds = append(cmds, cmd)
+		amount -= size
+	}
+	return
+}
+
+//通知后台检查池水位，不阻塞
+func (s *Service) signalVidPool() {
+	select {
+	case s.vidPoolSignal <- struct{}{}:
+	default:
+	}
+}
+
+//为区块分配池中的 vid，区块以待上链状态插入，返回分配到 vid 的区块和剩余区块
+func (s *Service) takePooledVids(session *xorm.Session, blocks []*Block) (pooled, rest []*Block, err error) {
+	rest = blocks
+	if s.config.VidPoolHighWatermark <= 0 || len(blocks) == 0 {
+		return
+	}
+	var vids []*PooledVid
+	err = session.Where("state=?", VidPoolStateAvailable).Asc("id").Limit(len(blocks)).ForUpdate().Find(&vids)
+	if err != nil {
+		log.Error("%+v", err.Error())
+		return
+	}
+	for k, v := range vids {
+		b := blocks[k]
+		b.Vid = v.Vid
+		b.ScanUrl = v.ScanUrl
+		b.State = BlockStateToPost
+		_, err = session.Insert(b)
+		if err != nil {
+			log.Error("%+v", err.Error())
+			return
+		}
+		v.State = VidPoolStateAssigned
+		v.Hash = b.Hash
+		_, err = session.ID(v.Id).Cols("state", "hash").Update(v)
+		if err != nil {
+			log.Error("%+v", err.Error())
+			return
+		}
+	}
+	pooled = blocks[:len(vids)]
+	rest = blocks[len(vids):]
+	return
+}
+
+// FillVidPoolCommand 为池抢占 vid 的命令，与 OccupyVidCommand 共用抢占接口，成功后不发起上链
+type FillVidPoolCommand struct {
+	id      int64
+	state   CommandState
+	vids    []*PooledVid
+	payload string //持久化的请求报文
+	ctx     context.Context
+}
+
+func NewFillVidPoolCommand(session *xorm.Session, ctx context.Context, generator VidGenerator, amount int) (cmd *FillVidPoolCommand, err error) {
+	cmdM := &CommandModel{}
+	cmdM.State = CommandStateOfGenerating
+	cmdM.Cmd = Command_Fill_Vid_Pool
+	_, err = session.Insert(cmdM)
+	if err != nil {
+		log.Error("%+v", err.Error())
+		return
+	}
+	cmd = new(FillVidPoolCommand)
+	for i := 0; i < amount; i++ {
+		b := &Block{Hash: fmt.Sprintf("%s%d-%d", vidPoolHashPrefix, cmdM.Id, i)}
+		err = b.GenerateVid(generator)
+		if err != nil {
+			log.Error("%+v", err.Error())
+			return
+		}
+		v := &PooledVid{Vid: b.Vid, State: VidPoolStateOccupying, CommandId: cmdM.Id}
+		_, err = session.Insert(v)
+		if err != nil {
+			log.Error("%+v", err.Error())
+			return
+		}
+		cmd.vids = append(cmd.vids, v)
+	}
+	cmd.id = cmdM.Id
+	cmd.state = cmdM.State
+	cmd.ctx = ctx
+	cmd.payload, err = savePayload(session, cmd.id, cmd.buildRequest())
+	return
+}
+
+func newFillVidPoolCommandFromModel(session *xorm.Session, ctx context.Context, cm *CommandModel) (cmd *FillVidPoolCommand, err error) {
+	cmd = new(FillVidPoolCommand)
+	cmd.id = cm.Id
+	cmd.state = cm.State
+	cmd.ctx = ctx
+	cmd.payload = cm.Payload
+	err = session.Where("command_id=?", cm.Id).Find(&cmd.vids)
+	if err != nil {
+		log.Error("%+v", err.Error())
+	}
+	return
+}
+
+func (self *FillVidPoolCommand) Execute(service *Service) (err error) {
+	defer func() {
+		if err != nil {
+			service.publishCommandFailed(self.id, "", nil, err.Error())
+			session := service.dbEngine.NewSession()
+			defer session.Close()
+			_, updateErr := session.Where("command_id=? and state=?", self.id, VidPoolStateOccupying).Cols("state").Update(&PooledVid{State: VidPoolStateFailed})
+			if updateErr != nil {
+				log.Error("%+v", updateErr.Error())
+			}
+			_, updateErr = session.ID(self.id).Update(&CommandModel{Error: err.Error(), State: CommandStateOfFail})
+			if updateErr != nil {
+				log.Error("%+v", updateErr.Error())
+			}
+		}
+		service.RunningCommandIds.Delete(self.id)
+		log.Debug("complete FillVidPoolCommand!")
+	}()
+
+	request := &OccupyVidRequest{}
+	if self.payload != "" {
+		err = json.Unmarshal([]byte(self.payload), request)
+		if err != nil {
+			log.Error("%+v", err.Error())
+			return
+		}
+	} else {
+		request = self.buildRequest()
+	}
+
+	ctx := context.WithValue(self.ctx, "request", request)
+	response, err := OccupyVid(ctx, service.config, service.Token)
+	if err != nil {
+		log.Error("%+v", err.Error())
+		return
+	}
+	if response.Status != string(CommandStateOfSuccess) {
+		err = fmt.Errorf(response.Status)
+		log.Error("%+v", err.Error())
+		return
+	}
+	self.state = CommandStateOfSuccess
+	err = self.next(service.dbEngine.NewSession(), response)
+	if err != nil {
+		log.Error("%+v", err.Error())
+		return
+	}
+	if len(response.FailureList) > 0 { //抢占失败的 vid 已删除，重新检查水位
+		service.signalVidPool()
+	}
+	return
+}
+
+func (self *FillVidPoolCommand) buildRequest() (request *OccupyVidRequest) {
+	request = &OccupyVidRequest{}
+	request.RequestNo = strconv.FormatInt(self.id, 10)
+	for _, v := range self.vids {
+		request.VidList = append(request.VidList, v.Vid)
+	}
+	return
+}
+
+//成功的 vid 变为可分配，其余的删除
+func (self *FillVidPoolCommand) next(session *xorm.Session, response *OccupyVidResponse) (err error) {
+	persistMutex.Lock()
+	defer persistMutex.Unlock()
+	defer session.Close()
+	err = session.Begin()
+	if err != nil {
+		log.Error("%+v", err.Error())
+		return
+	}
+	defer func() {
+		if err != nil {
+			session.Rollback()
+		} else {
+			err = session.Commit()
+		}
+	}()
+
+	_, err = session.ID(self.id).Update(&CommandModel{State: CommandStateOfSuccess})
+	if err != nil {
+		log.Error(err.Error())
+		return
+	}
+	if len(response.SuccessList) > 0 {
+		_, err = session.Where("command_id=?", self.id).In("vid", response.SuccessList).Cols("state", "scan_url").
+			Update(&PooledVid{State: VidPoolStateAvailable, ScanUrl: response.Url})
+		if err != nil {
+			log.Error(err.Error())
+			return
+		}
+	}
+	_, err = session.Where("command_id=? and state in (?,?)", self.id, VidPoolStateOccupying, VidPoolStateFailed).Delete(&PooledVid{})
+	if err != nil {
+		log.Error(err.Error())
+	}
+	return
+}
+
+func (self *FillVidPoolCommand) GetId() int64           { return self.id }
+func (self *FillVidPoolCommand) GetState() CommandState { return self.state }
+func (self *FillVidPoolCommand) GetBlocks() []*Block    { return nil } //池中的 vid 还没有对应的区块
@@ -0,0 +1,283 @@
+package vechain
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestChunkBlocks(t *testing.T) {
+	var blocks []*Block
+	for i := 0; i < 5; i++ {
+		blocks = append(blocks, &Block{})
+	}
+	for _, c := range []struct {
+		size  int
+		sizes []int
+	}{
+		{2, []int{2, 2, 1}},
+		{5, []int{5}},
+		{100, []int{5}},
+	} {
+		chunks := chunkBlocks(blocks, c.size)
+		if len(chunks) != len(c.sizes) {
+			t.Fatalf("size %d: expect %d chunks, got %d", c.size, len(c.sizes), len(chunks))
+		}
+		for k, v := range chunks {
+			if len(v) != c.sizes[k] {
+				t.Errorf("size %d: chunk %d has %d blocks", c.size, k, len(v))
+			}
+		}
+	}
+	if chunks := chunkBlocks(nil, 2); len(chunks) != 0 {
+		t.Errorf("expect no chunk, got %d", len(chunks))
+	}
+}
+
+func TestFillVidPoolCommand_buildRequest(t *testing.T) {
+	cmd := &FillVidPoolCommand{id: 7, vids: []*PooledVid{{Vid: "V1"}, {Vid: "V2"}}}
+	request := cmd.buildRequest()
+	if request.RequestNo != "7" || strings.Join(request.VidList, ",") != "V1,V2" {
+		t.Errorf("unexpected request %+v", request)
+	}
+	if cmd.GetBlocks() != nil {
+		t.Error("pool command should have no blocks")
+	}
+}
+
+func TestVidPoolConfig(t *testing.T) {
+	cfg := &VechainConfig{VidPoolHighWatermark: 1000}
+	cfg.SetDefaults()
+	if cfg.VidPoolLowWatermark != 500 {
+		t.Errorf("expect default low watermark 500, got %d", cfg.VidPoolLowWatermark)
+	}
+
+	cfg = &VechainConfig{VidPoolHighWatermark: 100, VidPoolLowWatermark: 100}
+	if err := cfg.Validate(); err == nil || !strings.Contains(err.Error(), "VidPoolLowWatermark") {
+		t.Errorf("expect watermark error, got %v", err)
+	}
+
+	cfg = &VechainConfig{VidPoolHighWatermark: 100, VidStrategy: VidStrategyDeterministic}
+	if err := cfg.Validate(); err == nil || !strings.Contains(err.Error(), "cannot be used with VidPoolHighWatermark") {
+		t.Errorf("expect deterministic pool error, got %v", err)
+	}
+
+	//未启用时不阻塞
+	new(Service).signalVidPool()
+}
+
+func insertTestPooledVids(t *testing.T, s *Service, vids ...*PooledVid) []*PooledVid {
+	for _, v := range vids {
+		if _, err := s.dbEngine.Insert(v); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return vids
+}
+
+func TestDispatchVid_Pooled(t *testing.T) {
+	s, cleanup := newTestService(t, &VechainConfig{VidPoolHighWatermark: 10})
+	defer cleanup()
+	events, cancel := s.Subscribe(EventFilter{Types: []EventType{EventOccupied}})
+	defer cancel()
+	vids := insertTestPooledVids(t, s,
+		&PooledVid{Vid: "P1", ScanUrl: "https://scan/", State: VidPoolStateAvailable},
+		&PooledVid{Vid: "P2", State: VidPoolStateOccupying},
+		&PooledVid{Vid: "P3", State: VidPoolStateAvailable},
+	)
+
+	blocks := []*Block{
+		{Hash: "H1", Account: DefaultAccountKey, State: BlockStateToOccupy},
+		{Hash: "H2", Account: DefaultAccountKey, State: BlockStateToOccupy},
+		{Hash: "H3", Account: DefaultAccountKey, State: BlockStateToOccupy},
+	}
+	if err := s.dispatchVid(DefaultAccountKey, blocks); err != nil {
+		t.Fatal(err)
+	}
+	//按 id 顺序分配可分配的 vid，剩余区块走抢占流程
+	for k, expect := range []string{"P1", "P3"} {
+		if b := getTestBlock(t, s, blocks[k].Id); b.Vid != expect || b.State != BlockStateToPost {
+			t.Errorf("block %d: expect %s to post, got %+v", k, expect, *b)
+		}
+	}
+	if b := getTestBlock(t, s, blocks[2].Id); b.State != BlockStateToOccupy || b.Vid == "P2" {
+		t.Errorf("block 2 should be occupied normally, got %+v", *b)
+	}
+	for k, v := range vids {
+		pv := new(PooledVid)
+		if _, err := s.dbEngine.ID(v.Id).Get(pv); err != nil {
+			t.Fatal(err)
+		}
+		if expect := map[int]string{0: "H1", 2: "H2"}[k]; pv.Hash != expect || (expect != "") != (pv.State == VidPoolStateAssigned) {
+			t.Errorf("pooled vid %s: unexpected %+v", v.Vid, *pv)
+		}
+	}
+	var post, occupy int
+	for len(s.CommandChan) > 0 {
+		switch (<-s.CommandChan).(type) {
+		case *PostArtifactCommand:
+			post++
+		case *OccupyVidCommand:
+			occupy++
+		}
+	}
+	if post != 1 || occupy != 1 {
+		t.Errorf("expect 1 post and 1 occupy command, got %d %d", post, occupy)
+	}
+	if len(events) != 2 || len(s.vidPoolSignal) != 1 {
+		t.Errorf("expect 2 occupied events and a pool signal, got %d %d", len(events), len(s.vidPoolSignal))
+	}
+}
+
+func TestDispatchVid_PooledRollback(t *testing.T) {
+	s, cleanup := newTestService(t, &VechainConfig{VidPoolHighWatermark: 10})
+	defer cleanup()
+	events, cancel := s.Subscribe(EventFilter{Types: []EventType{EventOccupied}})
+	defer cancel()
+	vids := insertTestPooledVids(t, s, &PooledVid{Vid: "P1", State: VidPoolStateAvailable})
+
+	//未注册的账户取不到 uid，事务回滚
+	if err := s.dispatchVid("unknown", []*Block{{Hash: "H1", Account: "unknown"}}); err == nil {
+		t.Fatal("expect error for unknown account")
+	}
+	if len(events) != 0 || len(s.CommandChan) != 0 || len(s.vidPoolSignal) != 0 {
+		t.Errorf("nothing should be published after rollback, got events:%d commands:%d", len(events), len(s.CommandChan))
+	}
+	pv := new(PooledVid)
+	if _, err := s.dbEngine.ID(vids[0].Id).Get(pv); err != nil || pv.State != VidPoolStateAvailable || pv.Hash != "" {
+		t.Errorf("pooled vid should stay available, got %+v %v", *pv, err)
+	}
+	if n, _ := s.dbEngine.Count(&Block{}); n != 0 {
+		t.Errorf("expect no block, got %d", n)
+	}
+}
+
+func TestFillVidPool(t *testing.T) {
+	s, cleanup := newTestService(t, &VechainConfig{VidPoolHighWatermark: 10, VidPoolLowWatermark: 5, ItemAmountPerRequest: 3})
+	defer cleanup()
+	vids := insertTestPooledVids(t, s,
+		&PooledVid{Vid: "P1", State: VidPoolStateAvailable},
+		&PooledVid{Vid: "P2", State: VidPoolStateAvailable},
+		&PooledVid{Vid: "P3", State: VidPoolStateOccupying},
+	)
+	//超时仍在抢占中的 vid 标记为失败，不计入水位
+	stale := &PooledVid{CommonModel: CommonModel{Updated: time.Now().Add(-2 * s.config.CommandExpireDuration)}}
+	if _, err := s.dbEngine.ID(vids[2].Id).NoAutoTime().Cols("updated").Update(stale); err != nil {
+		t.Fatal(err)
+	}
+
+	n, err := s.FillVidPool()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != 8 {
+		t.Errorf("expect to fill 8 vids, got %d", n)
+	}
+	var sizes []int
+	for len(s.CommandChan) > 0 {
+		cmd := (<-s.CommandChan).(*FillVidPoolCommand)
+		sizes = append(sizes, len(cmd.vids))
+		if _, running := s.RunningCommandIds.Load(cmd.GetId()); !running {
+			t.Errorf("command %d should be marked running", cmd.GetId())
+		}
+	}
+	if fmt.Sprint(sizes) != "[3 3 2]" {
+		t.Errorf("expect commands of [3 3 2], got %v", sizes)
+	}
+	stats, err := s.VidPoolStats()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stats.Available != 2 || stats.Occupying != 8 || stats.Failed != 1 {
+		t.Errorf("unexpected stats %+v", *stats)
+	}
+
+	//可分配和抢占中的 vid 高于低水位，不再抢占
+	if n, err = s.FillVidPool(); err != nil || n != 0 || len(s.CommandChan) != 0 {
+		t.Errorf("expect no fill above low watermark, got %d %v", n, err)
+	}
+}
+
+func TestFillVidPoolCommand_next(t *testing.T) {
+	s, cleanup := newTestService(t, &VechainConfig{VidPoolHighWatermark: 10, ItemAmountPerRequest: 3})
+	defer cleanup()
+	cmds, err := s.newFillVidPoolCommands(3)
+	if err != nil || len(cmds) != 1 {
+		t.Fatalf("expect 1 command, got %d %v", len(cmds), err)
+	}
+	cmd := cmds[0].(*FillVidPoolCommand)
+	response := &OccupyVidResponse{
+		Url:         "https://scan/",
+		SuccessList: []string{cmd.vids[0].Vid, cmd.vids[2].Vid},
+		FailureList: []string{cmd.vids[1].Vid},
+	}
+	if err = cmd.next(s.dbEngine.NewSession(), response); err != nil {
+		t.Fatal(err)
+	}
+
+	var vids []*PooledVid
+	if err = s.dbEngine.Where("command_id=?", cmd.GetId()).Asc("id").Find(&vids); err != nil {
+		t.Fatal(err)
+	}
+	if len(vids) != 2 {
+		t.Fatalf("failed vid should be deleted, got %d vids", len(vids))
+	}
+	for k, v := range vids {
+		if expect := response.SuccessList[k]; v.Vid != expect || v.State != VidPoolStateAvailable || v.ScanUrl != response.Url {
+			t.Errorf("expect %s available, got %+v", expect, *v)
+		}
+	}
+	cm := new(CommandModel)
+	if _, err = s.dbEngine.ID(cmd.GetId()).Get(cm); err != nil || cm.State != CommandStateOfSuccess {
+		t.Errorf("expect command success, got %s %v", cm.State, err)
+	}
+}
+
+func TestNewFillVidPoolCommands_Generator(t *testing.T) {
+	s, cleanup := newTestService(t, &VechainConfig{VidPoolHighWatermark: 10})
+	defer cleanup()
+	//使用服务自身的生成方式，与全局 Daemon 无关
+	s.VidGenerator = &DeterministicVidGenerator{Prefix: "9227.cn.v."}
+	cmds, err := s.newFillVidPoolCommands(2)
+	if err != nil || len(cmds) != 1 {
+		t.Fatalf("expect 1 command, got %d %v", len(cmds), err)
+	}
+	for _, v := range cmds[0].(*FillVidPoolCommand).vids {
+		if !strings.HasPrefix(v.Vid, "9227.cn.v.") {
+			t.Errorf("vid not generated by service generator: %s", v.Vid)
+		}
+	}
+}
+
+func TestFillVidPool_CrashedOccupying(t *testing.T) {
+	s, cleanup := newTestService(t, &VechainConfig{VidPoolHighWatermark: 3, VidPoolLowWatermark: 1})
+	defer cleanup()
+	vids := insertTestPooledVids(t, s,
+		&PooledVid{Vid: "P1", State: VidPoolStateOccupying, CommandId: 1}, //命令已随进程退出
+		&PooledVid{Vid: "P2", State: VidPoolStateOccupying, CommandId: 2}, //命令仍在本进程运行
+		&PooledVid{Vid: "P3", State: VidPoolStateOccupying, CommandId: 3}, //刚发起抢占
+	)
+	old := &PooledVid{CommonModel: CommonModel{Updated: time.Now().Add(-2 * VidPoolOccupyingTimeout)}}
+	for _, v := range vids[:2] {
+		if _, err := s.dbEngine.ID(v.Id).NoAutoTime().Cols("updated").Update(old); err != nil {
+			t.Fatal(err)
+		}
+	}
+	s.RunningCommandIds.Store(int64(2), true)
+
+	if n, err := s.FillVidPool(); err != nil || n != 0 {
+		t.Errorf("expect no fill above low watermark, got %d %v", n, err)
+	}
+	stats, err := s.VidPoolStats()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stats.Occupying != 2 || stats.Failed != 1 {
+		t.Errorf("unexpected stats %+v", *stats)
+	}
+	p1 := new(PooledVid)
+	if _, err = s.dbEngine.ID(vids[0].Id).Get(p1); err != nil || p1.State != VidPoolStateFailed {
+		t.Errorf("expect P1 failed, got %+v %v", p1, err)
+	}
+}
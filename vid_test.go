@@ -0,0 +1,90 @@
+package vechain
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func TestVidGenerator(t *testing.T) {
+	b := &Block{Hash: "H1"}
+
+	random, err := NewVidGenerator(&VechainConfig{VeVid: "9227.cn.v"}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	v1, _ := random.Generate(b, 0)
+	v2, _ := random.Generate(b, 0)
+	if !strings.HasPrefix(v1, "9227.cn.v.") || len(v1) != len("9227.cn.v.")+vidDigestLength || v1 == v2 {
+		t.Errorf("unexpected random vid %s %s", v1, v2)
+	}
+	if err = ValidateVid(v1); err != nil {
+		t.Error(err)
+	}
+
+	deterministic, _ := NewVidGenerator(&VechainConfig{VidStrategy: VidStrategyDeterministic}, nil)
+	d0, _ := deterministic.Generate(b, 0)
+	d0Again, _ := deterministic.Generate(b, 0)
+	d1, _ := deterministic.Generate(b, 1)
+	if !strings.HasPrefix(d0, vidLegacyPrefix) || d0 != d0Again || d0 == d1 {
+		t.Errorf("unexpected deterministic vid %s %s %s", d0, d0Again, d1)
+	}
+	//未配置 VeVid 时与原有格式一致：0X + 64 位摘要
+	if len(d0) != len(vidLegacyPrefix)+64 {
+		t.Errorf("legacy vid should keep the full digest: %s", d0)
+	}
+
+	if _, err = NewVidGenerator(&VechainConfig{VidStrategy: "uuid"}, nil); err == nil {
+		t.Error("invalid strategy should fail")
+	}
+}
+
+func TestValidateVid(t *testing.T) {
+	cases := []struct {
+		vid string
+		ok  bool
+	}{
+		{"9227.cn.v.000000000001", true},
+		{"0XABCDEF", true},
+		{"9227.cn.v/1", true},
+		{"", false},
+		{"9227 1", false},
+		{"9227\n1", false},
+		{strings.Repeat("A", VidMaxLength+1), false},
+	}
+	for _, c := range cases {
+		if err := ValidateVid(c.vid); (err == nil) != c.ok {
+			t.Errorf("ValidateVid(%q) = %v", c.vid, err)
+		}
+	}
+}
+
+func TestSequentialVidGenerator_reserve(t *testing.T) {
+	s, cleanup := newTestService(t, &VechainConfig{})
+	defer cleanup()
+
+	generator := NewSequentialVidGenerator(s.dbEngine, "9227.cn.v.")
+	for i, expect := range []int64{VidSequenceStep, 2 * VidSequenceStep} {
+		limit, err := generator.reserve()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if limit != expect {
+			t.Errorf("reserve %d: expect %d, got %d", i, expect, limit)
+		}
+	}
+
+	//新的生成器从未预留的序号开始，跳过上一个生成器未用完的序号
+	generator = NewSequentialVidGenerator(s.dbEngine, "9227.cn.v.")
+	vid, err := generator.Generate(&Block{Hash: "H1"}, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if expect := fmt.Sprintf("9227.cn.v.%012d", 2*VidSequenceStep+1); vid != expect {
+		t.Errorf("expect %s, got %s", expect, vid)
+	}
+	seq := new(VidSequence)
+	if has, err := s.dbEngine.Where("name=?", vidSequenceRowName).Get(seq); err != nil || !has || seq.Value != 3*VidSequenceStep {
+		t.Errorf("expect counter %d, got %+v %v", 3*VidSequenceStep, *seq, err)
+	}
+}